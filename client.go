@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rancher/container-crontab/control"
+	"github.com/urfave/cli"
+)
+
+func controlClient(c *cli.Context) *control.Client {
+	return control.NewClient(c.GlobalString("socket"))
+}
+
+func jobArg(c *cli.Context) (string, error) {
+	if c.NArg() != 1 {
+		return "", cli.NewExitError(fmt.Sprintf("%s requires exactly one job name or ID", c.Command.Name), 1)
+	}
+	return c.Args().First(), nil
+}
+
+func listJobs(c *cli.Context) error {
+	jobs, err := controlClient(c).ListJobs()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tACTION\tSCHEDULE\tSTATE\tLAST RUN\tNEXT RUN")
+	for _, job := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			shortID(job.ID), job.Name, job.Action, job.Schedule, jobState(job.Active, job.Paused),
+			formatTime(job.Prev), formatTime(job.Next))
+	}
+	return w.Flush()
+}
+
+func inspectJob(c *cli.Context) error {
+	ref, err := jobArg(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := controlClient(c).Inspect(ref)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	out, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func jobHistory(c *cli.Context) error {
+	ref, err := jobArg(c)
+	if err != nil {
+		return err
+	}
+
+	history, err := controlClient(c).History(ref)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tSTARTED\tDURATION\tACTION\tTRIGGER\tATTEMPT\tRESULT")
+	for _, run := range history {
+		result := "ok"
+		if run.Error != "" {
+			result = run.Error
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			run.Run, formatTime(run.Start), run.End.Sub(run.Start).Round(time.Millisecond), run.Action, run.Trigger, run.Attempt, result)
+	}
+	return w.Flush()
+}
+
+func jobLogs(c *cli.Context) error {
+	ref, err := jobArg(c)
+	if err != nil {
+		return err
+	}
+
+	output, err := controlClient(c).Logs(ref, c.Int("run"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Print(output.Output)
+	return nil
+}
+
+func auditLog(c *cli.Context) error {
+	records, err := controlClient(c).Audit(c.Args().First(), c.Int("limit"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tJOB\tACTION\tTRIGGER\tDECISION\tRUN\tREASON")
+	for _, record := range records {
+		reason := record.Reason
+		if reason == "" {
+			reason = record.Error
+		}
+		if reason == "" {
+			reason = "-"
+		}
+		run := "-"
+		if record.Run != 0 {
+			run = fmt.Sprintf("%d", record.Run)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatTime(record.Time), record.Job, record.Action, record.Trigger, record.Decision, run, reason)
+	}
+	return w.Flush()
+}
+
+func healthcheck(c *cli.Context) error {
+	client := controlClient(c)
+	if url := c.String("url"); url != "" {
+		client = control.NewURLClient(url, c.String("token"))
+	} else if err := checkSocket(c.GlobalString("socket")); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	report, err := client.Health(!c.Bool("live"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	for _, check := range report.Checks {
+		status := "ok"
+		if !check.OK {
+			status = "FAIL"
+		}
+		fmt.Printf("%-4s %s: %s\n", status, check.Name, check.Message)
+	}
+	if !report.OK {
+		return cli.NewExitError("unhealthy", 1)
+	}
+	return nil
+}
+
+// checkSocket tells apart a daemon running without the control socket, which the
+// healthcheck cannot reach, from one that is not answering
+func checkSocket(socket string) error {
+	if socket == "" {
+		return fmt.Errorf("The control socket is disabled, pass --url to check the daemon through its metrics listener")
+	}
+	if _, err := os.Stat(socket); os.IsNotExist(err) {
+		return fmt.Errorf("No control socket at %s: the daemon is not running or was started with --socket= or another --socket, "+
+			"pass the same --socket or --url to check it through its metrics listener", socket)
+	}
+	return nil
+}
+
+func triggerJob(c *cli.Context) error {
+	ref, err := jobArg(c)
+	if err != nil {
+		return err
+	}
+
+	if err := controlClient(c).Trigger(ref); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Printf("Triggered %s\n", ref)
+	return nil
+}
+
+func pauseJob(c *cli.Context) error {
+	if err := controlClient(c).Pause(c.Args().First()); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+func resumeJob(c *cli.Context) error {
+	if err := controlClient(c).Resume(c.Args().First()); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+func jobState(active, paused bool) string {
+	switch {
+	case paused:
+		return "paused"
+	case active:
+		return "active"
+	}
+	return "inactive"
+}
+
+func shortID(id string) string {
+	parts := strings.SplitN(id, "/", 2)
+	container := parts[0]
+	prefix := ""
+	if i := strings.Index(container, ":"); i >= 0 {
+		prefix, container = container[:i+1], container[i+1:]
+	}
+	// Only the 64 character IDs of Docker containers are shortened, not pod IDs
+	if len(container) == 64 {
+		container = container[:12]
+	}
+	parts[0] = prefix + container
+	return strings.Join(parts, "/")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
@@ -0,0 +1,181 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/container-crontab/cron"
+	"github.com/rancher/container-crontab/events"
+)
+
+// Client talks to a running daemon over its control socket
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the socket at path
+func NewClient(path string) *Client {
+	return &Client{
+		baseURL: "http://container-crontab",
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// NewURLClient returns a client for a daemon listening at baseURL, e.g. http://127.0.0.1:9191,
+// authenticating with token as a bearer token when set
+func NewURLClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Health returns the report of the /healthz probe, or of /readyz when ready is set
+func (c *Client) Health(ready bool) (events.HealthReport, error) {
+	path := "/healthz"
+	if ready {
+		path = "/readyz"
+	}
+
+	report := events.HealthReport{}
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return report, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return report, fmt.Errorf("unexpected response from daemon: %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	return report, err
+}
+
+// ListJobs returns every job known to the daemon
+func (c *Client) ListJobs() ([]cron.JobStatus, error) {
+	jobs := []cron.JobStatus{}
+	err := c.do(http.MethodGet, "/v1/jobs", &jobs)
+	return jobs, err
+}
+
+// Inspect returns the status and labels of a job
+func (c *Client) Inspect(ref string) (cron.JobStatus, error) {
+	status := cron.JobStatus{}
+	err := c.do(http.MethodGet, jobPath(ref, ""), &status)
+	return status, err
+}
+
+// History returns the recent runs of a job
+func (c *Client) History(ref string) ([]cron.RunRecord, error) {
+	history := []cron.RunRecord{}
+	err := c.do(http.MethodGet, jobPath(ref, "history"), &history)
+	return history, err
+}
+
+// Logs returns the captured output of run number run of a job, or of its latest run when run is 0
+func (c *Client) Logs(ref string, run int) (cron.RunOutput, error) {
+	output := cron.RunOutput{}
+	path := jobPath(ref, "logs")
+	if run != 0 {
+		path += "?run=" + strconv.Itoa(run)
+	}
+	err := c.do(http.MethodGet, path, &output)
+	return output, err
+}
+
+// Audit returns up to limit of the latest scheduling decisions about a job, or about every job when ref is empty
+func (c *Client) Audit(ref string, limit int) ([]cron.AuditRecord, error) {
+	query := url.Values{}
+	if ref != "" {
+		query.Set("job", ref)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	records := []cron.AuditRecord{}
+	err := c.do(http.MethodGet, "/v1/audit?"+query.Encode(), &records)
+	return records, err
+}
+
+// Trigger runs a job immediately
+func (c *Client) Trigger(ref string) error {
+	return c.do(http.MethodPost, jobPath(ref, "trigger"), nil)
+}
+
+// Pause pauses a single job, or the whole scheduler when ref is empty
+func (c *Client) Pause(ref string) error {
+	if ref == "" {
+		return c.do(http.MethodPost, "/v1/pause", nil)
+	}
+	return c.do(http.MethodPost, jobPath(ref, "pause"), nil)
+}
+
+// Resume resumes a single job, or the whole scheduler when ref is empty
+func (c *Client) Resume(ref string) error {
+	if ref == "" {
+		return c.do(http.MethodPost, "/v1/resume", nil)
+	}
+	return c.do(http.MethodPost, jobPath(ref, "resume"), nil)
+}
+
+func (c *Client) do(method, path string, result interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		errResp := errorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+			return fmt.Errorf("unexpected response from daemon: %s", resp.Status)
+		}
+		return errors.New(errResp.Error)
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func jobPath(ref, op string) string {
+	path := "/v1/jobs/" + url.PathEscape(ref)
+	if op != "" {
+		path += "/" + op
+	}
+	return path
+}
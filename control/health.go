@@ -0,0 +1,26 @@
+package control
+
+import (
+	"net/http"
+
+	"github.com/rancher/container-crontab/events"
+)
+
+// RegisterHealth adds the /healthz liveness and /readyz readiness probes to mux.
+// They answer 200 when every check passes and 503 otherwise.
+func RegisterHealth(mux *http.ServeMux, health *events.Health) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, health.Live())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, health.Ready())
+	})
+}
+
+func writeReport(w http.ResponseWriter, report events.HealthReport) {
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
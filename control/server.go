@@ -0,0 +1,239 @@
+package control
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/container-crontab/cron"
+	"github.com/rancher/container-crontab/events"
+)
+
+// DefaultSocket is where the daemon listens for client commands
+const DefaultSocket = "/var/run/container-crontab.sock"
+
+// Server exposes the crontab over a local Unix socket
+type Server struct {
+	Path    string
+	Crontab *cron.Crontab
+	Health  *events.Health
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer returns a control server for the crontab, health may be nil
+func NewServer(path string, crontab *cron.Crontab, health *events.Health) *Server {
+	return &Server{
+		Path:    path,
+		Crontab: crontab,
+		Health:  health,
+	}
+}
+
+// ListenAndServe replaces any stale socket at Path and serves requests on it.
+// The socket is only accessible by the user running the daemon.
+func (s *Server) ListenAndServe() error {
+	if info, err := os.Stat(s.Path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(s.Path)
+	}
+
+	listener, err := listenPrivate(s.Path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	logrus.Infof("Control socket listening on %s", s.Path)
+	return http.Serve(listener, s.Handler())
+}
+
+// listenPrivate listens on a Unix socket at path that only its owner can
+// connect to. The socket is created with the umask's permissions, so it is
+// created in a directory only the owner can enter and moved to path once
+// restricted.
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".container-crontab")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, filepath.Base(path))
+	listener, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, err
+	}
+	// The socket is moved away from where the listener would remove it on close
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(private, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(private, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &unlinkListener{Listener: listener, path: path}, nil
+}
+
+// unlinkListener removes its socket when closed
+type unlinkListener struct {
+	net.Listener
+	path string
+}
+
+func (l *unlinkListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// Handler returns the HTTP handler serving the control API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/jobs", s.listJobs)
+	mux.HandleFunc("/v1/jobs/", s.job)
+	mux.HandleFunc("/v1/audit", s.audit)
+	mux.HandleFunc("/v1/pause", s.pause)
+	mux.HandleFunc("/v1/resume", s.resume)
+	if s.Health != nil {
+		RegisterHealth(mux, s.Health)
+	}
+	return mux
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Crontab.ListJobs())
+}
+
+// job serves /v1/jobs/<ref>[/history|/logs|/trigger|/pause|/resume]
+func (s *Server) job(w http.ResponseWriter, r *http.Request) {
+	// The reference is escaped by the client since named jobs contain a slash
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.EscapedPath(), "/v1/jobs/"), "/", 2)
+	ref, err := url.PathUnescape(parts[0])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	op := ""
+	if len(parts) == 2 {
+		op = parts[1]
+	}
+
+	if ref == "" {
+		writeError(w, http.StatusNotFound, "job reference required")
+		return
+	}
+
+	method := http.MethodPost
+	if op == "" || op == "history" || op == "logs" {
+		method = http.MethodGet
+	}
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var result interface{}
+
+	switch op {
+	case "":
+		result, err = s.Crontab.JobStatus(ref)
+	case "history":
+		var jobEntry *cron.JobEntry
+		if jobEntry, err = s.Crontab.GetJob(ref); err == nil {
+			result = jobEntry.Job.History()
+		}
+	case "logs":
+		run := 0
+		if value := r.URL.Query().Get("run"); value != "" {
+			if run, err = strconv.Atoi(value); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid run number: "+value)
+				return
+			}
+		}
+		result, err = s.Crontab.JobOutput(ref, run)
+	case "trigger":
+		err = s.Crontab.TriggerJob(ref)
+	case "pause":
+		err = s.Crontab.PauseJob(ref)
+	case "resume":
+		err = s.Crontab.ResumeJob(ref)
+	default:
+		writeError(w, http.StatusNotFound, "unknown operation: "+op)
+		return
+	}
+
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// audit serves /v1/audit[?job=<ref>][&limit=<n>]
+func (s *Server) audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid limit: "+value)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, s.Crontab.Audit(r.URL.Query().Get("job"), limit))
+}
+
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.Crontab.Pause()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.Crontab.Resume()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Errorf("Error writing control response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
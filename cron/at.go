@@ -0,0 +1,50 @@
+package cron
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AtSchedule fires once at each of a fixed list of times. It implements the
+// robfig/cron Schedule interface and returns the zero time after the last one.
+type AtSchedule struct {
+	Times []time.Time
+}
+
+// ParseAtSchedule parses a comma separated list of RFC3339 timestamps
+func ParseAtSchedule(value string) (*AtSchedule, error) {
+	schedule := &AtSchedule{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, field)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cron.at timestamp: %s", err)
+		}
+		schedule.Times = append(schedule.Times, t)
+	}
+
+	if len(schedule.Times) == 0 {
+		return nil, fmt.Errorf("No timestamps found in cron.at: %s", value)
+	}
+
+	sort.Slice(schedule.Times, func(i, j int) bool {
+		return schedule.Times[i].Before(schedule.Times[j])
+	})
+
+	return schedule, nil
+}
+
+// Next returns the first time after t, or the zero time when all have passed
+func (s *AtSchedule) Next(t time.Time) time.Time {
+	for _, at := range s.Times {
+		if at.After(t) {
+			return at
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,251 @@
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// DecisionRan marks ticks on which the job's action was executed
+	DecisionRan = "ran"
+	// DecisionSkipped marks ticks on which the job did not run
+	DecisionSkipped = "skipped"
+
+	// DefaultAuditSize is the size at which the audit file is rotated by default
+	DefaultAuditSize = 10 * 1024 * 1024
+	// DefaultAuditKeep is how many rotated audit files are kept by default
+	DefaultAuditKeep = 5
+
+	// maxRecentAudit is how many records are kept in memory for clients
+	maxRecentAudit = 1000
+	// maxPausedAudit is how many ticks missed while the cron runner was paused
+	// are recorded per job, the rest of the pause is covered by one more record
+	maxPausedAudit = 100
+)
+
+// AuditRecord explains what the scheduler decided when a job was due and why
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id"`
+	Job       string    `json:"job"`
+	Action    string    `json:"action"`
+	Trigger   string    `json:"trigger"`
+	Scheduled time.Time `json:"scheduled"`
+	Decision  string    `json:"decision"`
+	Reason    string    `json:"reason,omitempty"`
+	Run       int       `json:"run,omitempty"`
+	Attempt   int       `json:"attempt,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// AuditLog appends AuditRecords as JSON lines to a file rotated once it
+// reaches maxSize, and keeps the most recent ones in memory. An empty path
+// keeps them in memory only.
+type AuditLog struct {
+	path    string
+	maxSize int64
+	keep    int
+	file    *os.File
+	size    int64
+	recent  []AuditRecord
+	lock    sync.Mutex
+}
+
+// NewAuditLog returns an audit log writing to path, keeping keep rotated files of at most maxSize bytes
+func NewAuditLog(path string, maxSize int64, keep int) *AuditLog {
+	if maxSize <= 0 {
+		maxSize = DefaultAuditSize
+	}
+	if keep < 0 {
+		keep = DefaultAuditKeep
+	}
+	return &AuditLog{
+		path:    path,
+		maxSize: maxSize,
+		keep:    keep,
+	}
+}
+
+// Record adds a record to the log
+func (a *AuditLog) Record(record AuditRecord) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.recent = append(a.recent, record)
+	if len(a.recent) > maxRecentAudit {
+		a.recent = a.recent[len(a.recent)-maxRecentAudit:]
+	}
+
+	if a.path == "" {
+		return
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		logrus.Errorf("Error encoding audit record: %s", err)
+		return
+	}
+	data = append(data, '\n')
+
+	if err := a.open(); err != nil {
+		logrus.Errorf("Error opening audit log: %s", err)
+		return
+	}
+	if a.size > 0 && a.size+int64(len(data)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			logrus.Errorf("Error rotating audit log: %s", err)
+			return
+		}
+	}
+
+	n, err := a.file.Write(data)
+	a.size += int64(n)
+	if err != nil {
+		logrus.Errorf("Error writing audit log: %s", err)
+	}
+}
+
+// Recent returns up to limit of the latest records, oldest first, of the job
+// with the given key, or of all jobs when key is empty
+func (a *AuditLog) Recent(key string, limit int) []AuditRecord {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	records := []AuditRecord{}
+	for i := len(a.recent) - 1; i >= 0 && (limit <= 0 || len(records) < limit); i-- {
+		if key == "" || a.recent[i].ID == key {
+			records = append(records, a.recent[i])
+		}
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records
+}
+
+func (a *AuditLog) open() error {
+	if a.file != nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	a.file = file
+	a.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.1 to path.2 and so on, dropping the oldest, then moves
+// the current file to path.1 and starts a new one
+func (a *AuditLog) rotate() error {
+	a.file.Close()
+	a.file = nil
+
+	if a.keep == 0 {
+		os.Remove(a.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", a.path, a.keep))
+		for i := a.keep - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+		}
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			return err
+		}
+	}
+
+	return a.open()
+}
+
+// audit records a decision taken about the job
+func (dj *DockerJob) audit(record AuditRecord) {
+	if dj.auditLog == nil {
+		return
+	}
+
+	record.Time = time.Now()
+	record.ID = dj.Key()
+	record.Job = dj.FullName()
+	record.Action = dj.Action
+	dj.auditLog.Record(record)
+}
+
+// skip logs and audits a tick on which the job does not run
+func (dj *DockerJob) skip(trigger string, scheduled time.Time, reason string) {
+	logrus.Infof("Skipping: %s on %s, %s", dj.Action, dj.Key(), reason)
+	dj.audit(AuditRecord{
+		Trigger:   trigger,
+		Scheduled: scheduled,
+		Decision:  DecisionSkipped,
+		Reason:    reason,
+	})
+}
+
+// skipPaused audits the ticks of the job between from and to, missed because
+// the whole cron runner was paused. It runs with the jobs locked, so it stops
+// walking the schedule after maxPausedAudit ticks: a job ticking every second
+// paused for a week would otherwise compute 600000 of them.
+func (dj *DockerJob) skipPaused(from, to time.Time) {
+	if dj.schedule == nil || dj.auditLog == nil {
+		return
+	}
+
+	tick := dj.schedule.Next(from)
+	for missed := 0; !tick.IsZero() && tick.Before(to); missed++ {
+		if missed == maxPausedAudit {
+			logrus.Infof("Skipped: more than %d ticks of %s while paused from %s to %s",
+				maxPausedAudit, dj.Key(), from.Format(time.RFC3339), to.Format(time.RFC3339))
+			dj.audit(AuditRecord{
+				Trigger:   TriggerSchedule,
+				Scheduled: tick,
+				Decision:  DecisionSkipped,
+				Reason:    fmt.Sprintf("paused until %s, further ticks not recorded", to.Format(time.RFC3339)),
+			})
+			return
+		}
+
+		dj.audit(AuditRecord{
+			Trigger:   TriggerSchedule,
+			Scheduled: tick,
+			Decision:  DecisionSkipped,
+			Reason:    "paused",
+		})
+		tick = dj.schedule.Next(tick)
+	}
+}
+
+// auditRun records a tick on which the job's action was executed. Runs that
+// failed because the container is gone are recorded as skipped.
+func (dj *DockerJob) auditRun(record RunRecord, err error) {
+	decision := AuditRecord{
+		Trigger:   record.Trigger,
+		Scheduled: record.Scheduled,
+		Decision:  DecisionRan,
+		Run:       record.Run,
+		Attempt:   record.Attempt,
+		Error:     record.Error,
+	}
+	if err != nil && client.IsErrContainerNotFound(err) {
+		decision.Decision = DecisionSkipped
+		decision.Reason = "container does not exist"
+	}
+	dj.audit(decision)
+}
@@ -0,0 +1,232 @@
+package cron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a period of time during which jobs must not run
+type Window interface {
+	Contains(time.Time) bool
+	String() string
+}
+
+// weeklyWindow repeats every week, or every day when daily is set.
+// Start and end are offsets in minutes from the beginning of the week (or day).
+type weeklyWindow struct {
+	start, end int
+	daily      bool
+	text       string
+}
+
+func (w weeklyWindow) Contains(t time.Time) bool {
+	offset := t.Hour()*60 + t.Minute()
+	if !w.daily {
+		offset += int(t.Weekday()) * 24 * 60
+	}
+
+	if w.start <= w.end {
+		return offset >= w.start && offset < w.end
+	}
+	// The window wraps around the end of the week (or day)
+	return offset >= w.start || offset < w.end
+}
+
+func (w weeklyWindow) String() string {
+	return w.text
+}
+
+// periodWindow covers a fixed period, or the same period every year when yearly is set
+type periodWindow struct {
+	start, end time.Time
+	yearly     bool
+	name       string
+}
+
+func (w periodWindow) Contains(t time.Time) bool {
+	if !w.yearly {
+		return !t.Before(w.start) && t.Before(w.end)
+	}
+
+	// Check the occurrence starting this year and the one starting last year,
+	// in case it spans the turn of the year
+	for _, year := range []int{t.Year() - 1, t.Year()} {
+		years := year - w.start.Year()
+		if years < 0 {
+			continue
+		}
+		start, end := w.start.AddDate(years, 0, 0), w.end.AddDate(years, 0, 0)
+		if !t.Before(start) && t.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w periodWindow) String() string {
+	if w.name != "" {
+		return w.name
+	}
+	return fmt.Sprintf("%s to %s", w.start.Format(time.RFC3339), w.end.Format(time.RFC3339))
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseBlackout parses a comma separated list of blackout windows. Each one is either
+//   - a weekly window, e.g. "Fri 18:00-Mon 06:00"
+//   - a daily window, e.g. "22:00-06:00"
+//   - a period of dates or RFC3339 timestamps, e.g. "2026-12-24/2026-12-26", where dates are inclusive
+func ParseBlackout(value string) ([]Window, error) {
+	windows := []Window{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		var (
+			window Window
+			err    error
+		)
+		if strings.Contains(field, "/") {
+			window, err = parsePeriodWindow(field)
+		} else {
+			window, err = parseWeeklyWindow(field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+func parseWeeklyWindow(field string) (Window, error) {
+	bounds := strings.Split(strings.Replace(field, "–", "-", -1), "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("Invalid blackout window: %s", field)
+	}
+
+	start, startHasDay, err := parseWeekTime(bounds[0])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid blackout window: %s: %s", field, err)
+	}
+	end, endHasDay, err := parseWeekTime(bounds[1])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid blackout window: %s: %s", field, err)
+	}
+	if startHasDay != endHasDay {
+		return nil, fmt.Errorf("Invalid blackout window: %s: either both or none of the bounds need a weekday", field)
+	}
+
+	return weeklyWindow{
+		start: start,
+		end:   end,
+		daily: !startHasDay,
+		text:  field,
+	}, nil
+}
+
+// parseWeekTime parses "[Day ]HH:MM" into minutes from the start of the week or day
+func parseWeekTime(value string) (int, bool, error) {
+	fields := strings.Fields(value)
+	day := -1
+	switch len(fields) {
+	case 1:
+	case 2:
+		name := strings.ToLower(fields[0])
+		if len(name) > 3 {
+			name = name[:3]
+		}
+		weekday, ok := weekdays[name]
+		if !ok {
+			return 0, false, fmt.Errorf("unknown weekday: %s", fields[0])
+		}
+		day = int(weekday)
+		fields = fields[1:]
+	default:
+		return 0, false, fmt.Errorf("expected [Day ]HH:MM, got: %s", value)
+	}
+
+	t, err := time.Parse("15:04", fields[0])
+	if err != nil {
+		return 0, false, err
+	}
+
+	offset := t.Hour()*60 + t.Minute()
+	if day >= 0 {
+		return day*24*60 + offset, true, nil
+	}
+	return offset, false, nil
+}
+
+func parsePeriodWindow(field string) (Window, error) {
+	bounds := strings.Split(field, "/")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("Invalid blackout period: %s", field)
+	}
+
+	start, _, err := parseDateOrTime(bounds[0])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid blackout period: %s: %s", field, err)
+	}
+	end, endIsDate, err := parseDateOrTime(bounds[1])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid blackout period: %s: %s", field, err)
+	}
+	if endIsDate {
+		// The end date is part of the period
+		end = end.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("Invalid blackout period: %s: end is not after start", field)
+	}
+
+	return periodWindow{
+		start: start,
+		end:   end,
+		name:  field,
+	}, nil
+}
+
+func parseDateOrTime(value string) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// blackedOut returns the window t falls into, from the job's own blackout labels or
+// the global calendar
+func (dj *DockerJob) blackedOut(t time.Time) (Window, bool) {
+	for _, window := range dj.blackout {
+		if window.Contains(t) {
+			return window, true
+		}
+	}
+
+	if dj.calendar != nil && !dj.ignoreCalendar {
+		return dj.calendar.Match(t)
+	}
+	return nil, false
+}
+
+// blackoutReason returns why a run at t must be skipped, or "" if it may run
+func (dj *DockerJob) blackoutReason(t time.Time) string {
+	if window, ok := dj.blackedOut(t); ok {
+		return fmt.Sprintf("inside blackout window: %s", window)
+	}
+	return ""
+}
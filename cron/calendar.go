@@ -0,0 +1,222 @@
+package cron
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Calendar holds the holidays and freeze periods during which no job runs
+type Calendar struct {
+	Windows []Window
+}
+
+type yamlCalendar struct {
+	Holidays []struct {
+		Date   string `yaml:"date"`
+		Name   string `yaml:"name"`
+		Yearly bool   `yaml:"yearly"`
+	} `yaml:"holidays"`
+	Freezes []struct {
+		Start string `yaml:"start"`
+		End   string `yaml:"end"`
+		Name  string `yaml:"name"`
+	} `yaml:"freezes"`
+}
+
+// LoadCalendar reads an iCalendar (.ics) or YAML calendar file
+func LoadCalendar(path string) (*Calendar, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".ics") {
+		return parseICalendar(data)
+	}
+	return parseYAMLCalendar(data)
+}
+
+// Match returns the window t falls into
+func (c *Calendar) Match(t time.Time) (Window, bool) {
+	for _, window := range c.Windows {
+		if window.Contains(t) {
+			return window, true
+		}
+	}
+	return nil, false
+}
+
+func parseYAMLCalendar(data []byte) (*Calendar, error) {
+	config := yamlCalendar{}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, err
+	}
+
+	calendar := &Calendar{}
+	for _, holiday := range config.Holidays {
+		day, err := time.ParseInLocation("2006-01-02", holiday.Date, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid holiday date: %s", err)
+		}
+		calendar.Windows = append(calendar.Windows, periodWindow{
+			start:  day,
+			end:    day.AddDate(0, 0, 1),
+			yearly: holiday.Yearly,
+			name:   holidayName(holiday.Name, holiday.Date),
+		})
+	}
+
+	for _, freeze := range config.Freezes {
+		start, _, err := parseDateOrTime(freeze.Start)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid freeze start: %s", err)
+		}
+		end, endIsDate, err := parseDateOrTime(freeze.End)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid freeze end: %s", err)
+		}
+		if endIsDate {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("Invalid freeze: %s, end is not after start", freeze.Name)
+		}
+		calendar.Windows = append(calendar.Windows, periodWindow{
+			start: start,
+			end:   end,
+			name:  holidayName(freeze.Name, freeze.Start+"/"+freeze.End),
+		})
+	}
+
+	return calendar, nil
+}
+
+// parseICalendar reads the VEVENTs of an iCalendar file. Only yearly recurrence
+// rules are supported, events with any other RRULE are rejected.
+func parseICalendar(data []byte) (*Calendar, error) {
+	calendar := &Calendar{}
+
+	var (
+		event   map[string]string
+		params  map[string]string
+		inEvent bool
+	)
+
+	for _, line := range unfoldICalendar(data) {
+		name, param, value := splitICalendarLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event, params, inEvent = map[string]string{}, map[string]string{}, true
+		case name == "END" && value == "VEVENT":
+			window, err := icalendarWindow(event, params)
+			if err != nil {
+				return nil, err
+			}
+			calendar.Windows = append(calendar.Windows, window)
+			inEvent = false
+		case inEvent:
+			event[name] = value
+			params[name] = param
+		}
+	}
+
+	return calendar, nil
+}
+
+func icalendarWindow(event, params map[string]string) (Window, error) {
+	start, startIsDate, err := parseICalendarTime(event["DTSTART"], params["DTSTART"])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid DTSTART in event %s: %s", event["SUMMARY"], err)
+	}
+
+	end := start.AddDate(0, 0, 1)
+	if value, ok := event["DTEND"]; ok {
+		if end, _, err = parseICalendarTime(value, params["DTEND"]); err != nil {
+			return nil, fmt.Errorf("Invalid DTEND in event %s: %s", event["SUMMARY"], err)
+		}
+	} else if !startIsDate {
+		return nil, fmt.Errorf("Event %s has a start time but no DTEND", event["SUMMARY"])
+	}
+
+	yearly := false
+	if rule, ok := event["RRULE"]; ok {
+		if !strings.Contains(strings.ToUpper(rule), "FREQ=YEARLY") {
+			return nil, fmt.Errorf("Unsupported RRULE in event %s: %s", event["SUMMARY"], rule)
+		}
+		yearly = true
+	}
+
+	return periodWindow{
+		start:  start,
+		end:    end,
+		yearly: yearly,
+		name:   holidayName(event["SUMMARY"], event["DTSTART"]),
+	}, nil
+}
+
+func parseICalendarTime(value, param string) (time.Time, bool, error) {
+	loc := time.Local
+	for _, p := range strings.Split(param, ";") {
+		if strings.HasPrefix(p, "TZID=") {
+			var err error
+			if loc, err = time.LoadLocation(strings.Trim(p[5:], `"`)); err != nil {
+				return time.Time{}, false, err
+			}
+		}
+	}
+
+	switch {
+	case len(value) == 8:
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// unfoldICalendar joins continuation lines, which start with a space or tab
+func unfoldICalendar(data []byte) []string {
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitICalendarLine splits "NAME;PARAMS:VALUE"
+func splitICalendarLine(line string) (string, string, string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", ""
+	}
+	name, value := line[:i], line[i+1:]
+
+	param := ""
+	if j := strings.Index(name, ";"); j >= 0 {
+		name, param = name[:j], name[j+1:]
+	}
+	return strings.ToUpper(name), param, value
+}
+
+func holidayName(name, fallback string) string {
+	if name != "" {
+		return name
+	}
+	return fallback
+}
@@ -0,0 +1,74 @@
+package cron
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/robfig/cron.v2"
+)
+
+// maxCatchupScan bounds the walk over fire times missed during a long downtime
+const maxCatchupScan = 100000
+
+// missedRun returns the latest fire time of schedule after last and not after now,
+// or the zero time if none was missed
+func missedRun(schedule cron.Schedule, last, now time.Time) time.Time {
+	var missed time.Time
+	if last.IsZero() {
+		return missed
+	}
+
+	next := schedule.Next(last)
+	for i := 0; i < maxCatchupScan && !next.IsZero() && !next.After(now); i++ {
+		missed = next
+		next = schedule.Next(next)
+	}
+
+	return missed
+}
+
+// catchUp runs the job once if its schedule fired while the daemon was not running
+// and the missed run is still within the starting deadline. It returns whether a run was started.
+func (dj *DockerJob) catchUp() bool {
+	if !dj.catchup || dj.state == nil || dj.schedule == nil {
+		return false
+	}
+
+	now := time.Now()
+	last := dj.state.Get(dj.Key()).LastTick
+	if last.IsZero() {
+		// First time this job is seen, start counting missed runs from now on
+		dj.recordTick(now)
+		return false
+	}
+
+	missed := missedRun(dj.schedule, last, now)
+	if missed.IsZero() {
+		return false
+	}
+
+	if dj.startingDeadline > 0 && now.Sub(missed) > dj.startingDeadline {
+		dj.skip(TriggerCatchup, missed, fmt.Sprintf("missed while the daemon was down, starting deadline of %s has passed", dj.startingDeadline))
+		return false
+	}
+
+	logrus.Infof("Catching up run of %s missed at %s", dj.ID, missed)
+	dj.recordTick(now)
+	if dj.IsPaused() {
+		dj.skip(TriggerCatchup, missed, "paused")
+		return false
+	}
+	go dj.run(TriggerCatchup, missed, 0)
+	return true
+}
+
+// recordTick remembers the last fire time of jobs catching up on missed runs
+func (dj *DockerJob) recordTick(t time.Time) {
+	if !dj.catchup || dj.state == nil {
+		return
+	}
+	dj.state.Update(dj.Key(), func(state *JobState) {
+		state.LastTick = t
+	})
+}
@@ -0,0 +1,127 @@
+package cron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Outcomes of a job a chained job can wait for
+const (
+	AfterSuccess = "success"
+	AfterFailure = "failure"
+	AfterAlways  = "always"
+)
+
+// chainCondition is parsed from cron.after=<container-name>[:success|failure|always]
+type chainCondition struct {
+	Name string
+	On   string
+}
+
+func parseAfter(value string) (*chainCondition, error) {
+	condition := &chainCondition{
+		Name: strings.TrimPrefix(strings.TrimSpace(value), "/"),
+		On:   AfterSuccess,
+	}
+
+	if i := strings.LastIndex(condition.Name, ":"); i >= 0 {
+		condition.Name, condition.On = condition.Name[:i], strings.ToLower(condition.Name[i+1:])
+	}
+
+	switch condition.On {
+	case AfterSuccess, AfterFailure, AfterAlways:
+	default:
+		return nil, fmt.Errorf("Invalid cron.after outcome: %s, expected success, failure or always", condition.On)
+	}
+
+	if condition.Name == "" {
+		return nil, fmt.Errorf("Invalid cron.after: %s, container name required", value)
+	}
+
+	return condition, nil
+}
+
+func (c *chainCondition) String() string {
+	return fmt.Sprintf("after %s:%s", c.Name, c.On)
+}
+
+func (c *chainCondition) matches(err error) bool {
+	switch c.On {
+	case AfterSuccess:
+		return err == nil
+	case AfterFailure:
+		return err != nil
+	}
+	return true
+}
+
+// checkChainCycle follows the cron.after chain starting at after and fails if it
+// leads back to name. The caller must hold jobsLock.
+func (ct *Crontab) checkChainCycle(name string, after *chainCondition) error {
+	visited := map[string]bool{}
+	path := []string{name}
+
+	for current := after; current != nil; {
+		path = append(path, current.Name)
+		if current.Name == name {
+			return fmt.Errorf("cron.after creates a cycle: %s", strings.Join(path, " -> "))
+		}
+		if visited[current.Name] {
+			return nil
+		}
+		visited[current.Name] = true
+
+		next := ct.jobByName(current.Name)
+		if next == nil {
+			return nil
+		}
+		current = next.after
+	}
+
+	return nil
+}
+
+// jobByName returns the job of a container name, or container/job name for
+// named jobs. The caller must hold jobsLock.
+func (ct *Crontab) jobByName(name string) *DockerJob {
+	for _, jobEntry := range ct.jobs {
+		if jobEntry.Job.FullName() == name {
+			return jobEntry.Job
+		}
+	}
+	return nil
+}
+
+// jobFinished runs the jobs chained after job, depending on its outcome
+func (ct *Crontab) jobFinished(job *DockerJob, err error) {
+	if job.Name == "" {
+		return
+	}
+	name := job.FullName()
+
+	ct.jobsLock.RLock()
+	dependents := []*DockerJob{}
+	for _, jobEntry := range ct.jobs {
+		after := jobEntry.Job.after
+		if after != nil && after.Name == name && after.matches(err) {
+			dependents = append(dependents, jobEntry.Job)
+		}
+	}
+	ct.jobsLock.RUnlock()
+
+	for _, dependent := range dependents {
+		logrus.Infof("Running: %s on %s, chained after %s", dependent.Action, dependent.Key(), name)
+		go dependent.runChained()
+	}
+}
+
+func (dj *DockerJob) runChained() {
+	if dj.IsPaused() {
+		dj.skip(TriggerChain, time.Now(), "paused")
+		return
+	}
+	dj.run(TriggerChain, time.Now(), 0)
+}
@@ -3,9 +3,11 @@ package cron
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/rancher/container-crontab/notify"
 	"github.com/rancher/go-rancher-metadata/metadata"
 	"gopkg.in/robfig/cron.v2"
 )
@@ -18,8 +20,16 @@ type cronJob interface {
 type Crontab struct {
 	cronRunner *cron.Cron
 	jobs       map[string]*JobEntry
+	jobsLock   sync.RWMutex
+	state      *StateStore
+	calendar   *Calendar
+	outputs    *OutputStore
+	notifier   notify.Notifier
+	auditLog   *AuditLog
 	mdClient   metadata.Client
 	rancher    bool
+	paused     bool
+	pausedAt   time.Time
 }
 
 type JobEntry struct {
@@ -33,6 +43,9 @@ func NewCrontab() (*Crontab, error) {
 	crontab := &Crontab{
 		cronRunner: cron.New(),
 		jobs:       map[string]*JobEntry{},
+		state:      &StateStore{jobs: map[string]*JobState{}},
+		outputs:    NewOutputStore("", DefaultOutputSize, DefaultOutputKeep),
+		auditLog:   NewAuditLog("", DefaultAuditSize, DefaultAuditKeep),
 	}
 
 	crontab.cronRunner.Start()
@@ -58,66 +71,237 @@ func NewRancherTypeCrontab(metadataURL string) (*Crontab, error) {
 	return crontab, nil
 }
 
+// SetStateStore sets where job state is persisted, it must be called before jobs are added
+func (ct *Crontab) SetStateStore(store *StateStore) {
+	ct.state = store
+}
+
+// FlushState writes the pending job state changes to disk
+func (ct *Crontab) FlushState() {
+	if ct.state != nil {
+		ct.state.Flush()
+	}
+}
+
+// SetCalendar sets the holidays and freeze periods applying to every job, it must be called before jobs are added
+func (ct *Crontab) SetCalendar(calendar *Calendar) {
+	ct.calendar = calendar
+}
+
+// SetOutputStore sets where the output of runs is kept, it must be called before jobs are added
+func (ct *Crontab) SetOutputStore(store *OutputStore) {
+	ct.outputs = store
+}
+
+// SetNotifier sets where the outcome of runs is reported, it must be called before jobs are added
+func (ct *Crontab) SetNotifier(notifier notify.Notifier) {
+	ct.notifier = notifier
+}
+
+// SetAuditLog sets where scheduling decisions are recorded, it must be called before jobs are added
+func (ct *Crontab) SetAuditLog(auditLog *AuditLog) {
+	ct.auditLog = auditLog
+}
+
+// RancherMode reports whether job states follow Rancher metadata
+func (ct *Crontab) RancherMode() bool {
+	return ct.rancher
+}
+
+// CheckRancherMetadata checks the Rancher metadata service is reachable
+func (ct *Crontab) CheckRancherMetadata() error {
+	if !ct.rancher {
+		return nil
+	}
+	_, err := ct.mdClient.GetVersion()
+	return err
+}
+
 // GetEntries lists the cron entries
 func (ct *Crontab) GetEntries() []cron.Entry {
 	entries := ct.cronRunner.Entries()
 	return entries
 }
 
-// AddJob Adds a docker job to the crontab
-func (ct *Crontab) AddJob(id string, labels map[string]string, jobType string) error {
+// AddJob Adds the docker jobs configured by the labels of a container of endpoint to the crontab
+func (ct *Crontab) AddJob(endpoint *Endpoint, id, name string, labels map[string]string, jobType string) error {
+	ct.jobsLock.Lock()
+	defer ct.jobsLock.Unlock()
+
+	jobs := splitJobLabels(labels)
+	if len(jobs) == 0 {
+		return fmt.Errorf("No cron schedule found for container: %s", id)
+	}
+
+	var lastErr error
+	for jobName, jobLabels := range jobs {
+		if err := ct.addJob(endpoint, id, name, jobName, jobLabels, jobType); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// addJob adds a single job of a container. The caller must hold jobsLock.
+func (ct *Crontab) addJob(endpoint *Endpoint, id, name, jobName string, labels map[string]string, jobType string) error {
 	var job *DockerJob
 
-	if _, ok := ct.jobs[id]; ok {
-		logrus.Debugf("Ignoring Event: %d with job id: %d", id, ct.jobs[id])
+	key := jobKey(endpoint.containerKey(id), jobName)
+	if _, ok := ct.jobs[key]; ok {
+		logrus.Debugf("Ignoring Event: %s with job id: %d", key, ct.jobs[key].CronID)
 		return nil
 	}
 
-	schedule, ok := labels["cron.schedule"]
-	if !ok {
-		return fmt.Errorf("No cron schedule found for container: %s", id)
+	seed := key
+	if name != "" {
+		seed = HashSeed(endpoint.name(), name, jobName)
+	}
+	spec, schedule, err := parseJobSchedule(key, seed, labels)
+	if err != nil {
+		logrus.Errorf("error adding: %s. Got: %s", key, err)
+		return err
 	}
 
 	switch jobType {
 	case "docker":
-		job = NewDockerJob(id, labels)
+		job = NewDockerJob(id, name, labels)
+		job.JobName = jobName
+		job.endpoint = endpoint
+		if err := job.checkRuntime(); err != nil {
+			logrus.Errorf("error adding: %s. Got: %s", key, err)
+			return err
+		}
 	default:
 		logrus.Warnf("Unknown job type: %s", jobType)
+		return fmt.Errorf("Unknown job type: %s", jobType)
 	}
 
-	jobID, err := ct.cronRunner.AddJob(schedule, job)
-	if err != nil {
-		logrus.Errorf("error adding: %s. Got: %s", id, err)
+	if err := job.validate(); err != nil {
+		logrus.Errorf("error adding: %s. Got: %s", key, err)
 		return err
 	}
 
-	ct.jobs[id] = &JobEntry{
+	if job.after != nil {
+		// Jobs are chained after a container of the same endpoint unless another one is named
+		if endpoint.name() != "" && !strings.Contains(job.after.Name, ":") {
+			job.after.Name = endpoint.containerKey(job.after.Name)
+		}
+		if err := ct.checkChainCycle(job.FullName(), job.after); err != nil {
+			logrus.Errorf("error adding: %s. Got: %s", key, err)
+			return err
+		}
+	}
+
+	job.Schedule = spec
+	job.schedule = schedule
+	job.state = ct.state
+	job.calendar = ct.calendar
+	job.outputs = ct.outputs
+	job.notifier = ct.notifier
+	job.auditLog = ct.auditLog
+	job.retire = func() { ct.retireJob(key) }
+	job.finished = func(err error) { ct.jobFinished(job, err) }
+	if job.delayAfterFinish > 0 {
+		job.reschedule = func(next time.Time) {
+			ct.rescheduleJob(key, &AtSchedule{Times: []time.Time{next}})
+		}
+	}
+
+	// Jobs chained after another one are only run by jobFinished
+	var jobID cron.EntryID
+	if schedule != nil {
+		jobID = ct.cronRunner.Schedule(schedule, job)
+	}
+
+	ct.jobs[key] = &JobEntry{
 		CronID: jobID,
 		Job:    job,
 	}
 
-	ct.setJobState(ct.jobs[id])
+	ct.setJobState(ct.jobs[key])
+	caughtUp := job.catchUp()
+
+	if !caughtUp && job.done(time.Now()) {
+		logrus.Infof("Not adding: %s, schedule: %s has no runs left", key, spec)
+		ct.cronRunner.Remove(jobID)
+		delete(ct.jobs, key)
+		return nil
+	}
 
-	logrus.Infof("Added: %s, with schedule: %s", id, schedule)
+	logrus.Infof("Added: %s, with schedule: %s", key, spec)
 	return nil
 }
 
-// RemoveJob remove a docker job from the cron queue
-func (ct *Crontab) RemoveJob(id string) {
-	if jobEntry, ok := ct.jobs[id]; ok {
+// RemoveJob remove the docker jobs of a container of endpoint from the cron queue
+func (ct *Crontab) RemoveJob(endpoint *Endpoint, id string) {
+	for _, key := range ct.containerJobKeys(endpoint, id) {
+		ct.removeJob(key)
+	}
+	ct.state.RemoveContainer(endpoint.containerKey(id))
+	ct.outputs.RemoveContainer(endpoint.containerKey(id))
+}
+
+// retireJob removes a job that has no runs left, keeping its state so it
+// is not picked up again when the daemon restarts
+func (ct *Crontab) retireJob(key string) {
+	ct.removeJob(key)
+}
+
+func (ct *Crontab) removeJob(key string) {
+	ct.jobsLock.Lock()
+	defer ct.jobsLock.Unlock()
+
+	if jobEntry, ok := ct.jobs[key]; ok {
+		ct.cronRunner.Remove(jobEntry.CronID)
+		jobEntry.Job.cancelRetry()
+		delete(ct.jobs, key)
+		logrus.Infof("Removed: %s", key)
+	}
+}
+
+// HasJobs tells whether a container of endpoint has jobs in the crontab
+func (ct *Crontab) HasJobs(endpoint *Endpoint, id string) bool {
+	return len(ct.containerJobKeys(endpoint, id)) > 0
+}
+
+// containerJobKeys returns the keys of all jobs of a container of endpoint
+func (ct *Crontab) containerJobKeys(endpoint *Endpoint, id string) []string {
+	ct.jobsLock.RLock()
+	defer ct.jobsLock.RUnlock()
+
+	keys := []string{}
+	for key, jobEntry := range ct.jobs {
+		if jobEntry.Job.ID == id && jobEntry.Job.Endpoint() == endpoint.name() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// rescheduleJob replaces the schedule of a job that is still in the crontab
+func (ct *Crontab) rescheduleJob(key string, schedule cron.Schedule) {
+	ct.jobsLock.Lock()
+	defer ct.jobsLock.Unlock()
+
+	if jobEntry, ok := ct.jobs[key]; ok {
 		ct.cronRunner.Remove(jobEntry.CronID)
-		delete(ct.jobs, id)
-		logrus.Infof("Removed: %s", id)
+		jobEntry.CronID = ct.cronRunner.Schedule(schedule, jobEntry.Job)
+		jobEntry.Job.schedule = schedule
 	}
 }
 
-func (ct *Crontab) DeactivateJob(id string, labels map[string]string) error {
+func (ct *Crontab) DeactivateJob(endpoint *Endpoint, id string, labels map[string]string) error {
 	if !ct.rancher {
 		return nil
 	}
 
-	if jobEntry, ok := ct.jobs[id]; ok {
-		ct.setJobState(jobEntry)
+	ct.jobsLock.RLock()
+	defer ct.jobsLock.RUnlock()
+
+	for _, jobEntry := range ct.jobs {
+		if jobEntry.Job.ID == id && jobEntry.Job.Endpoint() == endpoint.name() {
+			ct.setJobState(jobEntry)
+		}
 	}
 
 	return nil
@@ -168,9 +352,11 @@ func (ct *Crontab) getRancherServiceByUUID(uuid string) (metadata.Service, error
 func (ct *Crontab) watchRancherMetadata() {
 	for {
 		logrus.Debug("Scanning Rancher Metadata")
+		ct.jobsLock.RLock()
 		for _, job := range ct.jobs {
 			ct.setJobState(job)
 		}
+		ct.jobsLock.RUnlock()
 		time.Sleep(getDuration(5))
 	}
 }
@@ -202,30 +388,34 @@ func (ct *Crontab) setJobState(job *JobEntry) {
 	}
 
 	// if the job is inactive...activate
-	if state == "active" && !job.Job.Active {
+	if state == "active" && !job.Job.IsActive() {
 		job.Job.Activate()
 	}
 
 	// if the job is active... Deactivate
-	if state != "active" && job.Job.Active {
+	if state != "active" && job.Job.IsActive() {
 		job.Job.Deactivate()
 	}
 }
 
-func (ct *Crontab) GetNumberOfActiveJobs() float64 {
+func (ct *Crontab) GetNumberOfActiveJobs(endpoint *Endpoint) float64 {
 	var i float64
+	ct.jobsLock.RLock()
+	defer ct.jobsLock.RUnlock()
 	for _, job := range ct.jobs {
-		if job.Job.Active {
+		if job.Job.IsActive() && job.Job.Endpoint() == endpoint.name() {
 			i++
 		}
 	}
 	return i
 }
 
-func (ct *Crontab) GetNumberOfInactiveJobs() float64 {
+func (ct *Crontab) GetNumberOfInactiveJobs(endpoint *Endpoint) float64 {
 	var i float64
+	ct.jobsLock.RLock()
+	defer ct.jobsLock.RUnlock()
 	for _, job := range ct.jobs {
-		if !job.Job.Active {
+		if !job.Job.IsActive() && job.Job.Endpoint() == endpoint.name() {
 			i++
 		}
 	}
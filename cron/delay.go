@@ -0,0 +1,19 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// scheduleAfterFinish schedules the next run of a cron.delay_after_finish job,
+// counting the delay from the moment the previous run completed
+func (dj *DockerJob) scheduleAfterFinish() {
+	if dj.delayAfterFinish <= 0 || dj.reschedule == nil {
+		return
+	}
+
+	next := time.Now().Add(dj.delayAfterFinish)
+	logrus.Debugf("Next run of %s scheduled at %s", dj.ID, next)
+	dj.reschedule(next)
+}
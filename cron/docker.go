@@ -2,25 +2,72 @@ package cron
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/rancher/container-crontab/kubernetes"
+	"github.com/rancher/container-crontab/notify"
+	"gopkg.in/robfig/cron.v2"
 )
 
 // DockerJob implements the cron job interface
 type DockerJob struct {
 	ID                 string
+	Name               string
+	JobName            string
 	Action             string
 	Schedule           string
 	Leader             bool
 	Labels             map[string]string
 	RancherServiceUUID string
-	Active             bool
 	lastError          error
+	lastOutput         string
 	restartTimeout     time.Duration
+	retries            int
+	retryBackoff       time.Duration
+	retryTimer         *time.Timer
+	retryLock          sync.Mutex
+	catchup            bool
+	startingDeadline   time.Duration
+	jitter             time.Duration
+	state              *StateStore
+	schedule           cron.Schedule
+	retire             func()
+	delayAfterFinish   time.Duration
+	reschedule         func(time.Time)
+	waitForExit        bool
+	blackout           []Window
+	calendar           *Calendar
+	ignoreCalendar     bool
+	notBefore          time.Time
+	notAfter           time.Time
+	maxRuns            int
+	after              *chainCondition
+	finished           func(error)
+	keepRuns           int
+	signalName         string
+	http               *httpAction
+	command            []string
+	container          string
+	execTimeout        time.Duration
+	outputs            *OutputStore
+	notifier           notify.Notifier
+	notifyOn           string
+	email              []string
+	pingURL            string
+	auditLog           *AuditLog
+	endpoint           *Endpoint
+	history            []RunRecord
+	historyLock        sync.Mutex
+	active             bool
+	paused             bool
+	stateLock          sync.Mutex
+	runLock            sync.Mutex
 }
 
 // Err returns last error message
@@ -28,12 +75,85 @@ func (dj *DockerJob) Err() error {
 	return dj.lastError
 }
 
+// Key identifies the job in the crontab: the container ID, followed by
+// the job name for jobs configured with cron.<name>.* labels. The ID is
+// prefixed with the endpoint name for containers of named Docker endpoints.
+func (dj *DockerJob) Key() string {
+	return jobKey(dj.endpoint.containerKey(dj.ID), dj.JobName)
+}
+
+// FullName is the container name, followed by the job name for named jobs
+// and prefixed with the endpoint name like Key
+func (dj *DockerJob) FullName() string {
+	return jobKey(dj.endpoint.containerKey(dj.Name), dj.JobName)
+}
+
+// Endpoint returns the name of the Docker endpoint running the container, "" for the default one
+func (dj *DockerJob) Endpoint() string {
+	return dj.endpoint.name()
+}
+
+func jobKey(container, jobName string) string {
+	if jobName == "" {
+		return container
+	}
+	return container + "/" + jobName
+}
+
 // Run Implements the job interface from cron package
 func (dj *DockerJob) Run() {
+	// A new tick supersedes any retries still pending from the previous one
+	dj.cancelRetry()
+	scheduled := time.Now()
+	dj.recordTick(scheduled)
+
+	if dj.IsPaused() {
+		dj.skip(TriggerSchedule, scheduled, "paused")
+		dj.finish()
+		return
+	}
+	dj.waitJitter()
+	dj.run(TriggerSchedule, scheduled, 0)
+}
+
+// Trigger runs the job right away, even when it is paused
+func (dj *DockerJob) Trigger() {
+	dj.cancelRetry()
+	dj.run(TriggerManual, time.Now(), 0)
+}
+
+func (dj *DockerJob) run(trigger string, scheduled time.Time, attempt int) {
+	dj.runLock.Lock()
+	defer dj.runLock.Unlock()
 	defer dj.resetErr()
 
-	if dj.Active {
+	if trigger != TriggerManual {
+		reason := dj.blackoutReason(time.Now())
+		if reason == "" {
+			reason = dj.validityReason(time.Now())
+		}
+		if reason != "" {
+			dj.skip(trigger, scheduled, reason)
+			dj.finish()
+			return
+		}
+	}
+
+	retrying := false
+	if dj.IsActive() {
 		logrus.Debugf("Executing: %s on %s", dj.Action, dj.ID)
+		if attempt == 0 {
+			dj.pingStart()
+		}
+		record := RunRecord{
+			Run:       dj.nextRun(),
+			Action:    dj.Action,
+			Trigger:   trigger,
+			Attempt:   attempt,
+			Scheduled: scheduled,
+			Start:     time.Now(),
+		}
+
 		switch dj.Action {
 		case "start":
 			dj.start()
@@ -41,14 +161,66 @@ func (dj *DockerJob) Run() {
 			dj.restart()
 		case "stop":
 			dj.stop()
+		case "pause":
+			dj.pause()
+		case "unpause":
+			dj.unpause()
+		case "signal":
+			dj.signal()
+		case "http":
+			dj.callHTTP()
+		case "exec":
+			dj.exec()
+		case "run":
+			dj.runEphemeral()
+		case "delete":
+			dj.podAction(kubernetes.DeletePod)
+		case "rollout-restart":
+			dj.podAction(kubernetes.RolloutRestart)
 		default:
-			logrus.Errorf("Unsupported action: %s for container id: %s", dj.Action, dj.ID)
+			dj.lastError = fmt.Errorf("Unsupported action: %s for container id: %s", dj.Action, dj.ID)
+		}
+
+		record.End = time.Now()
+		dj.saveOutput(record.Run)
+		if dj.Err() != nil {
+			record.Error = dj.Err().Error()
+		}
+		dj.auditRun(record, dj.Err())
+		dj.addRecord(record)
+
+		if dj.Err() != nil && attempt < dj.retries {
+			dj.scheduleRetry(trigger, scheduled, attempt+1)
+			retrying = true
+		}
+		if dj.Err() == nil {
+			dj.countSuccess()
 		}
+		if !retrying {
+			dj.notify(record)
+			dj.pingResult()
+			if dj.finished != nil {
+				dj.finished(dj.Err())
+			}
+		}
+	} else {
+		dj.skip(trigger, scheduled, "inactive, its Rancher service is not active")
 	}
 
 	if dj.Err() != nil {
 		logrus.Error(dj.Err())
 	}
+
+	if !retrying {
+		dj.finish()
+	}
+}
+
+// finish is called once a run, including its retries, is over or was skipped
+func (dj *DockerJob) finish() {
+	if !dj.retireIfDone() {
+		dj.scheduleAfterFinish()
+	}
 }
 
 func (dj *DockerJob) resetErr() {
@@ -56,53 +228,116 @@ func (dj *DockerJob) resetErr() {
 		logrus.Debugf("Reseting error on %s", dj.ID)
 	}
 	dj.lastError = nil
+	dj.lastOutput = ""
 }
 
 func (dj *DockerJob) start() {
-	var client *client.Client
-	client, dj.lastError = getDockerClient()
-	defer client.Close()
+	if dj.waitForExit {
+		dj.startAndWait()
+		return
+	}
 
-	if dj.Err() == nil {
-		dj.lastError = client.ContainerStart(context.Background(), dj.ID, types.ContainerStartOptions{})
+	var runtime Runtime
+	runtime, dj.lastError = dj.runtime()
+	if dj.Err() != nil {
+		return
 	}
+	defer runtime.Close()
+
+	dj.lastError = runtime.Start(context.Background(), dj.ID)
 }
 
-func (dj *DockerJob) restart() {
+// startAndWait starts the container and waits for it to exit, keeping its output
+func (dj *DockerJob) startAndWait() {
 	var client *client.Client
-	client, dj.lastError = getDockerClient()
-	defer client.Close()
+	client, dj.lastError = dj.dockerClient()
+	if dj.Err() != nil {
+		return
+	}
+
+	// Only the logs of this run are kept, the container may have run before
+	started := time.Now()
+	dj.lastError = client.ContainerStart(context.Background(), dj.ID, types.ContainerStartOptions{})
+	if dj.Err() != nil {
+		return
+	}
+
+	var status int64
+	status, dj.lastError = client.ContainerWait(context.Background(), dj.ID)
+
+	tty := false
+	if container, err := client.ContainerInspect(context.Background(), dj.ID); err == nil && container.Config != nil {
+		tty = container.Config.Tty
+	}
+	dj.lastOutput = dj.containerOutput(client, dj.ID, tty, started)
 
-	if dj.Err() == nil {
-		dj.lastError = client.ContainerRestart(context.Background(), dj.ID, &dj.restartTimeout)
+	if dj.Err() == nil && status != 0 {
+		dj.lastError = fmt.Errorf("container: %s exited with status %d", dj.ID, status)
 	}
 }
 
+func (dj *DockerJob) restart() {
+	var runtime Runtime
+	runtime, dj.lastError = dj.runtime()
+	if dj.Err() != nil {
+		return
+	}
+	defer runtime.Close()
+
+	dj.lastError = runtime.Restart(context.Background(), dj.ID, dj.restartTimeout)
+}
+
 func (dj *DockerJob) stop() {
-	var client *client.Client
-	client, dj.lastError = getDockerClient()
-	defer client.Close()
+	var runtime Runtime
+	runtime, dj.lastError = dj.runtime()
+	if dj.Err() != nil {
+		return
+	}
+	defer runtime.Close()
+
+	dj.lastError = runtime.Stop(context.Background(), dj.ID, dj.restartTimeout)
+}
 
-	if dj.Err() == nil {
-		dj.lastError = client.ContainerStop(context.Background(), dj.ID, &dj.restartTimeout)
+func (dj *DockerJob) pause() {
+	var runtime Runtime
+	runtime, dj.lastError = dj.runtime()
+	if dj.Err() != nil {
+		return
 	}
+	defer runtime.Close()
+
+	dj.lastError = runtime.Pause(context.Background(), dj.ID)
 }
 
-func getDockerClient() (*client.Client, error) {
-	return client.NewEnvClient()
+func (dj *DockerJob) unpause() {
+	var runtime Runtime
+	runtime, dj.lastError = dj.runtime()
+	if dj.Err() != nil {
+		return
+	}
+	defer runtime.Close()
+
+	dj.lastError = runtime.Unpause(context.Background(), dj.ID)
+}
+
+func (dj *DockerJob) dockerClient() (*client.Client, error) {
+	return dj.endpoint.Client()
 }
 
 // NewDockerJob creates a DockerJob and sets defaults
-func NewDockerJob(id string, labels map[string]string) *DockerJob {
+func NewDockerJob(id, name string, labels map[string]string) *DockerJob {
 	dj := &DockerJob{
 		ID:             id,
+		Name:           name,
 		Schedule:       labels["cron.schedule"],
 		Labels:         labels,
 		Action:         "start",
 		Leader:         false,
-		Active:         true,
+		active:         true,
 		lastError:      nil,
 		restartTimeout: getDuration(10),
+		retryBackoff:   defaultRetryBackoff,
+		notifyOn:       NotifyFailure,
 	}
 
 	if value, ok := labels["cron.action"]; ok {
@@ -123,16 +358,227 @@ func NewDockerJob(id string, labels map[string]string) *DockerJob {
 		dj.restartTimeout = getDuration(i)
 	}
 
+	if value, ok := labels["cron.retries"]; ok {
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 {
+			logrus.Errorf("Error converting cron.retries: %s to a positive int, retries are disabled", value)
+			i = 0
+		}
+		dj.retries = i
+	}
+
+	if value, ok := labels["cron.retry_backoff"]; ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			logrus.Errorf("Error parsing cron.retry_backoff: %s, sticking with default of %s", value, defaultRetryBackoff)
+			d = defaultRetryBackoff
+		}
+		dj.retryBackoff = d
+	}
+
+	if value, ok := labels["cron.catchup"]; ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			logrus.Errorf("Error parsing cron.catchup: %s, missed runs will not be caught up", value)
+		}
+		dj.catchup = b
+	}
+
+	if value, ok := labels["cron.starting_deadline"]; ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			logrus.Errorf("Error parsing cron.starting_deadline: %s, missed runs will not be caught up", value)
+		} else {
+			dj.catchup = true
+			dj.startingDeadline = d
+		}
+	}
+
+	if value, ok := labels["cron.delay_after_finish"]; ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			logrus.Errorf("Error parsing cron.delay_after_finish: %s", value)
+		} else {
+			dj.delayAfterFinish = d
+			dj.waitForExit = true
+		}
+	}
+
+	if value, ok := labels["cron.wait"]; ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			logrus.Errorf("Error parsing cron.wait: %s, runs end once the container started", value)
+		}
+		dj.waitForExit = dj.waitForExit || b
+	}
+
+	if value, ok := labels["cron.ignore_calendar"]; ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			logrus.Errorf("Error parsing cron.ignore_calendar: %s, the calendar applies", value)
+		}
+		dj.ignoreCalendar = b
+	}
+
+	if value, ok := labels["cron.after"]; ok {
+		// Validated when the schedule is parsed
+		dj.after, _ = parseAfter(value)
+	}
+
+	if value, ok := labels["cron.run.keep"]; ok {
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 {
+			logrus.Errorf("Error converting cron.run.keep: %s to a positive int, containers will be removed", value)
+			i = 0
+		}
+		dj.keepRuns = i
+	}
+
+	if value, ok := labels["cron.max_runs"]; ok {
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 {
+			logrus.Errorf("Error converting cron.max_runs: %s to a positive int, runs are not limited", value)
+			i = 0
+		}
+		dj.maxRuns = i
+	}
+
+	if value, ok := labels["cron.notify"]; ok {
+		mode, err := parseNotify(value)
+		if err != nil {
+			logrus.Errorf("%s, sticking with default of %s", err, NotifyFailure)
+		}
+		dj.notifyOn = mode
+	}
+
+	if value, ok := labels["cron.notify.email"]; ok {
+		addresses, err := notify.ParseAddresses(value)
+		if err != nil {
+			logrus.Errorf("Error parsing cron.notify.email: %s, no email will be sent", err)
+		}
+		dj.email = addresses
+	}
+
+	if value, ok := labels["cron.ping_url"]; ok {
+		pingURL, err := parsePingURL(value)
+		if err != nil {
+			logrus.Errorf("Error parsing cron.ping_url: %s, no pings will be sent", err)
+		}
+		dj.pingURL = pingURL
+	}
+
+	if value, ok := labels["cron.jitter"]; ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			logrus.Errorf("Error parsing cron.jitter: %s, runs will not be delayed", value)
+			d = 0
+		}
+		dj.jitter = d
+	}
+
 	return dj
 }
 
+// validate checks the settings that make the job unusable when wrong
+func (dj *DockerJob) validate() error {
+	if value, ok := dj.Labels["cron.blackout"]; ok {
+		windows, err := ParseBlackout(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing cron.blackout: %s", err)
+		}
+		dj.blackout = windows
+	}
+
+	if value, ok := dj.Labels["cron.not_before"]; ok {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("Error parsing cron.not_before: %s", err)
+		}
+		dj.notBefore = t
+	}
+
+	if value, ok := dj.Labels["cron.not_after"]; ok {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("Error parsing cron.not_after: %s", err)
+		}
+		dj.notAfter = t
+	}
+
+	switch dj.Action {
+	case "signal":
+		signal, err := parseSignal(dj.Labels["cron.signal"])
+		if err != nil {
+			return err
+		}
+		dj.signalName = signal
+	case "http":
+		action, err := parseHTTPAction(dj.Labels)
+		if err != nil {
+			return err
+		}
+		dj.http = action
+	case "exec":
+		command, err := parseCommand(dj.Labels["cron.command"])
+		if err != nil {
+			return err
+		}
+		dj.command = command
+		dj.container = dj.Labels["cron.container"]
+		if value, ok := dj.Labels["cron.exec.timeout"]; ok {
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return fmt.Errorf("cron.exec.timeout: %q is not a valid duration", value)
+			}
+			dj.execTimeout = timeout
+		}
+	}
+	return nil
+}
+
 // Deactivate Sets the Actve attribute to false. This will skip running
 func (dj *DockerJob) Deactivate() {
 	logrus.Debugf("Deactivating: %s", dj.ID)
-	dj.Active = false
+	dj.stateLock.Lock()
+	defer dj.stateLock.Unlock()
+	dj.active = false
 }
 
 func (dj *DockerJob) Activate() {
 	logrus.Debugf("Activating: %s", dj.ID)
-	dj.Active = true
+	dj.stateLock.Lock()
+	defer dj.stateLock.Unlock()
+	dj.active = true
+}
+
+// IsActive reports whether the container of the job is running. active and
+// paused are changed by the event handler, Rancher metadata and the control
+// API while runs read them, stateLock guards them.
+func (dj *DockerJob) IsActive() bool {
+	dj.stateLock.Lock()
+	defer dj.stateLock.Unlock()
+	return dj.active
+}
+
+// Pause keeps the job scheduled but skips its runs until Resume is called
+func (dj *DockerJob) Pause() {
+	logrus.Infof("Pausing: %s", dj.Key())
+	dj.stateLock.Lock()
+	defer dj.stateLock.Unlock()
+	dj.paused = true
+}
+
+// Resume lets a paused job run on its schedule again
+func (dj *DockerJob) Resume() {
+	logrus.Infof("Resuming: %s", dj.Key())
+	dj.stateLock.Lock()
+	defer dj.stateLock.Unlock()
+	dj.paused = false
+}
+
+// IsPaused reports whether the job's scheduled runs are skipped
+func (dj *DockerJob) IsPaused() bool {
+	dj.stateLock.Lock()
+	defer dj.stateLock.Unlock()
+	return dj.paused
 }
@@ -0,0 +1,279 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/rancher/container-crontab/kubernetes"
+)
+
+const (
+	// minAPIVersion is the version spoken with daemons that do not report theirs
+	minAPIVersion = "1.24"
+	// pingTimeout bounds the ping negotiating the API version
+	pingTimeout = 10 * time.Second
+)
+
+// defaultEndpoint keeps the negotiated API version of the daemon of jobs without an endpoint
+var defaultEndpoint = &Endpoint{}
+
+// Endpoint is a Docker daemon or a Kubernetes cluster whose containers are
+// scheduled. The zero value is the Docker daemon configured by the DOCKER_HOST,
+// DOCKER_CERT_PATH, DOCKER_TLS_VERIFY and DOCKER_API_VERSION environment variables.
+type Endpoint struct {
+	Name       string
+	Host       string
+	CertPath   string
+	TLSVerify  bool
+	APIVersion string
+	// Runtime is RuntimeDocker, the default, or RuntimeKubernetes
+	Runtime string
+	// Namespace is the Kubernetes namespace whose pods are scheduled, all of them when empty
+	Namespace string
+	// Selector is the label selector of the scheduled Kubernetes pods
+	Selector string
+	// Kubernetes is the client of the cluster of a RuntimeKubernetes endpoint
+	Kubernetes kubernetes.Client
+
+	version    string
+	podman     bool
+	httpClient *http.Client
+	pingURL    string
+	client     *client.Client
+	lock       sync.Mutex
+}
+
+// ParseEndpoint parses an endpoint given as comma separated key=value pairs, e.g.
+// name=build1,host=tcp://build1:2376,cert-path=/certs/build1,tls-verify=true
+func ParseEndpoint(value string) (*Endpoint, error) {
+	endpoint := &Endpoint{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid Docker endpoint setting: %s, expected key=value", field)
+		}
+		key, val := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		switch key {
+		case "name":
+			if strings.ContainsAny(val, ":/") {
+				return nil, fmt.Errorf("Invalid Docker endpoint name: %s, it must not contain : or /", val)
+			}
+			endpoint.Name = val
+		case "host":
+			endpoint.Host = val
+		case "cert-path":
+			endpoint.CertPath = val
+		case "tls-verify":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid Docker endpoint tls-verify: %s", val)
+			}
+			endpoint.TLSVerify = b
+		case "api-version":
+			endpoint.APIVersion = val
+		default:
+			return nil, fmt.Errorf("Unknown Docker endpoint setting: %s", key)
+		}
+	}
+
+	if endpoint.Host == "" {
+		return nil, fmt.Errorf("Docker endpoint: %s has no host", value)
+	}
+	if _, _, _, err := client.ParseHost(endpoint.Host); err != nil {
+		return nil, fmt.Errorf("Invalid Docker endpoint host: %s: %s", endpoint.Host, err)
+	}
+	return endpoint, nil
+}
+
+// String returns the name of the endpoint, "default" for an unnamed one
+func (e *Endpoint) String() string {
+	if e == nil || e.Name == "" {
+		return "default"
+	}
+	return e.Name
+}
+
+// Client returns the client of the endpoint's daemon, speaking the API version
+// negotiated with it unless one is pinned. It is created once the version is
+// known and shared by all jobs of the endpoint, along with its connections.
+func (e *Endpoint) Client() (*client.Client, error) {
+	if e.runtime() != RuntimeDocker {
+		return nil, fmt.Errorf("Docker endpoint: %s runs %s, not Docker", e, e.runtime())
+	}
+	if e == nil {
+		e = defaultEndpoint
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.client != nil {
+		return e.client, nil
+	}
+
+	host, _, _, version := e.settings()
+	if e.httpClient == nil {
+		if err := e.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	pinned := version != ""
+	if !pinned {
+		version = e.negotiate()
+	}
+	dClient, err := client.NewClient(host, version, e.httpClient, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Until the daemon answered, the next call negotiates again
+	if pinned || e.version != "" {
+		e.client = dClient
+	}
+	return dClient, nil
+}
+
+// connect sets up the HTTP client of the endpoint's daemon
+func (e *Endpoint) connect() error {
+	host, certPath, tlsVerify, _ := e.settings()
+
+	proto, addr, basePath, err := client.ParseHost(host)
+	if err != nil {
+		return err
+	}
+
+	transport := new(http.Transport)
+	sockets.ConfigureTransport(transport, proto, addr)
+	scheme := "http"
+	if certPath != "" {
+		tlsc, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile:             filepath.Join(certPath, "ca.pem"),
+			CertFile:           filepath.Join(certPath, "cert.pem"),
+			KeyFile:            filepath.Join(certPath, "key.pem"),
+			InsecureSkipVerify: !tlsVerify,
+		})
+		if err != nil {
+			return err
+		}
+		transport.TLSClientConfig = tlsc
+		scheme = "https"
+	}
+	e.httpClient = &http.Client{Transport: transport}
+
+	e.pingURL = scheme + "://" + addr + basePath + "/_ping"
+	if proto != "tcp" {
+		e.pingURL = scheme + "://docker" + basePath + "/_ping"
+	}
+	return nil
+}
+
+// settings returns the host, TLS settings and pinned API version of the
+// endpoint, read from the DOCKER_* environment variables for the default one
+func (e *Endpoint) settings() (string, string, bool, string) {
+	if e == nil || e.Host == "" {
+		host := os.Getenv("DOCKER_HOST")
+		if host == "" {
+			host = client.DefaultDockerHost
+		}
+		return host, os.Getenv("DOCKER_CERT_PATH"), os.Getenv("DOCKER_TLS_VERIFY") != "", os.Getenv("DOCKER_API_VERSION")
+	}
+	return e.Host, e.CertPath, e.TLSVerify, e.APIVersion
+}
+
+// negotiate returns the highest API version both the daemon and the client
+// speak. The result is kept once the daemon answered, until then every call
+// pings it again and falls back to the client's version. It is called with
+// e.lock held.
+func (e *Endpoint) negotiate() string {
+	if e.version != "" {
+		return e.version
+	}
+
+	serverVersion, podman, err := ping(e.httpClient, e.pingURL)
+	if err != nil {
+		logrus.Debugf("Error negotiating the API version of Docker endpoint: %s: %s", e, err)
+		return client.DefaultVersion
+	}
+
+	e.version = client.DefaultVersion
+	if serverVersion == "" {
+		// Daemons older than API 1.25 do not report their version
+		e.version = minAPIVersion
+	} else if versions.LessThan(serverVersion, e.version) {
+		e.version = serverVersion
+	}
+	e.podman = podman
+
+	daemon := "Docker"
+	if podman {
+		daemon = "Podman"
+	}
+	logrus.Infof("Using API version %s with %s daemon of endpoint: %s", e.version, daemon, e)
+	return e.version
+}
+
+// Podman tells whether the daemon of the endpoint was found to be Podman
+// serving the Docker API, which is only known once a client was created
+func (e *Endpoint) Podman() bool {
+	if e == nil {
+		e = defaultEndpoint
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.podman
+}
+
+// ping returns the API version reported by the daemon and whether it is Podman
+func ping(httpClient *http.Client, url string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return "", false, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	podman := resp.Header.Get("Libpod-API-Version") != "" || strings.HasPrefix(resp.Header.Get("Server"), "Libpod")
+	return resp.Header.Get("API-Version"), podman, nil
+}
+
+// containerKey qualifies a container ID or name with the name of the endpoint
+func (e *Endpoint) containerKey(container string) string {
+	if e == nil || e.Name == "" {
+		return container
+	}
+	return e.Name + ":" + container
+}
+
+// name returns the name of the endpoint, "" for an unnamed one
+func (e *Endpoint) name() string {
+	if e == nil {
+		return ""
+	}
+	return e.Name
+}
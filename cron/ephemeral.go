@@ -0,0 +1,175 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// templateLabel marks the containers created by the run action with the ID of their template
+const templateLabel = "io.rancher.container-crontab.template"
+
+// orchestratorLabels are the prefixes of the labels an orchestrator manages its
+// containers by. A clone keeping them would be taken for one of its containers,
+// e.g. counted as a replica of the template's service.
+var orchestratorLabels = []string{
+	"cron.",
+	"io.rancher.",
+	"com.docker.compose.",
+	"com.docker.swarm.",
+	"com.docker.stack.",
+	"io.kubernetes.",
+	"annotation.io.kubernetes.",
+}
+
+// runEphemeral implements the run action: it creates a new container from the
+// configuration of the labelled one, waits for it to exit and removes it
+func (dj *DockerJob) runEphemeral() {
+	var client *client.Client
+	client, dj.lastError = dj.dockerClient()
+	if dj.Err() != nil {
+		return
+	}
+
+	ctx := context.Background()
+	template, err := client.ContainerInspect(ctx, dj.ID)
+	if err != nil {
+		dj.lastError = err
+		return
+	}
+
+	config, hostConfig, networks := cloneContainerConfig(template, dj.ID)
+	// Runs of templates sharing a name, e.g. on several endpoints, can start within the same second
+	name := fmt.Sprintf("%s-run-%d-%08x", strings.TrimPrefix(template.Name, "/"), time.Now().Unix(), rand.Uint32())
+
+	// Only one network can be given on create, the others are connected afterwards
+	networkingConfig := &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{}}
+	networkNames := []string{}
+	for networkName := range networks {
+		networkNames = append(networkNames, networkName)
+	}
+	sort.Strings(networkNames)
+	if len(networkNames) > 0 {
+		networkingConfig.EndpointsConfig[networkNames[0]] = networks[networkNames[0]]
+	}
+
+	created, err := client.ContainerCreate(ctx, config, hostConfig, networkingConfig, name)
+	if err != nil {
+		dj.lastError = err
+		return
+	}
+	logrus.Debugf("Created: %s from template: %s", name, dj.ID)
+	defer dj.cleanupEphemeral(client)
+
+	if len(networkNames) > 1 {
+		for _, networkName := range networkNames[1:] {
+			if err := client.NetworkConnect(ctx, networkName, created.ID, networks[networkName]); err != nil {
+				dj.lastError = err
+				return
+			}
+		}
+	}
+
+	if err := client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		dj.lastError = err
+		return
+	}
+
+	status, err := client.ContainerWait(ctx, created.ID)
+	dj.lastOutput = dj.containerOutput(client, created.ID, config.Tty, time.Time{})
+	if err != nil {
+		dj.lastError = err
+		return
+	}
+	if status != 0 {
+		dj.lastError = fmt.Errorf("container: %s created from %s exited with status %d", name, dj.ID, status)
+	}
+}
+
+// cloneContainerConfig copies the configuration of template for a new container,
+// without its cron and orchestrator labels and without anything specific to the
+// template instance
+func cloneContainerConfig(template types.ContainerJSON, templateID string) (*container.Config, *container.HostConfig, map[string]*network.EndpointSettings) {
+	config := *template.Config
+	config.Hostname = ""
+	config.Labels = map[string]string{}
+	for key, value := range template.Config.Labels {
+		if !hasPrefix(key, orchestratorLabels) {
+			config.Labels[key] = value
+		}
+	}
+	config.Labels[templateLabel] = templateID
+
+	var hostConfig container.HostConfig
+	if template.HostConfig != nil {
+		hostConfig = *template.HostConfig
+	}
+	hostConfig.AutoRemove = false
+
+	networks := map[string]*network.EndpointSettings{}
+	if template.NetworkSettings != nil && !hostConfig.NetworkMode.IsContainer() && !hostConfig.NetworkMode.IsHost() {
+		for name, settings := range template.NetworkSettings.Networks {
+			endpoint := &network.EndpointSettings{}
+			if settings != nil {
+				endpoint.Links = settings.Links
+				for _, alias := range settings.Aliases {
+					// Docker adds the short container ID as an alias, it does not belong to the clone
+					if len(templateID) < 12 || alias != templateID[:12] {
+						endpoint.Aliases = append(endpoint.Aliases, alias)
+					}
+				}
+			}
+			networks[name] = endpoint
+		}
+	}
+
+	return &config, &hostConfig, networks
+}
+
+// cleanupEphemeral removes the containers created from the template, keeping the newest cron.run.keep ones
+func (dj *DockerJob) cleanupEphemeral(client *client.Client) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", templateLabel+"="+dj.ID)
+
+	containers, err := client.ContainerList(context.Background(), types.ContainerListOptions{
+		All:     true,
+		Filters: filterArgs,
+	})
+	if err != nil {
+		logrus.Errorf("Error listing containers created from: %s: %s", dj.ID, err)
+		return
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Created > containers[j].Created
+	})
+
+	for i, c := range containers {
+		if i < dj.keepRuns || c.State == "running" {
+			continue
+		}
+		logrus.Debugf("Removing: %s created from template: %s", c.ID, dj.ID)
+		if err := client.ContainerRemove(context.Background(), c.ID, types.ContainerRemoveOptions{RemoveVolumes: true}); err != nil {
+			logrus.Errorf("Error removing container: %s created from: %s: %s", c.ID, dj.ID, err)
+		}
+	}
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
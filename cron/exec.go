@@ -0,0 +1,64 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parseCommand reads cron.command either as a JSON array, run as is, or as a
+// string run by /bin/sh -c, like the two forms of a Dockerfile CMD
+func parseCommand(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("cron.command is required with the exec action")
+	}
+
+	if strings.HasPrefix(value, "[") {
+		command := []string{}
+		if err := json.Unmarshal([]byte(value), &command); err != nil {
+			return nil, fmt.Errorf("Error parsing cron.command: %s", err)
+		}
+		if len(command) == 0 {
+			return nil, fmt.Errorf("cron.command is required with the exec action")
+		}
+		return command, nil
+	}
+
+	return []string{"/bin/sh", "-c", value}, nil
+}
+
+// exec runs the command in the container and waits for it to exit, or for
+// cron.exec.timeout. The runtime cannot kill a command it timed out on, the
+// run fails and the command is left running in the container.
+func (dj *DockerJob) exec() {
+	var runtime Runtime
+	runtime, dj.lastError = dj.runtime()
+	if dj.Err() != nil {
+		return
+	}
+	defer runtime.Close()
+
+	ctx := context.Background()
+	if dj.execTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dj.execTimeout)
+		defer cancel()
+	}
+
+	out := newTailBuffer(dj.outputSize())
+	status, err := runtime.Exec(ctx, dj.ID, dj.container, dj.command, out)
+	dj.lastOutput = out.String()
+	if ctx.Err() == context.DeadlineExceeded {
+		dj.lastError = fmt.Errorf("command: %s in container: %s timed out after %s", strings.Join(dj.command, " "), dj.ID, dj.execTimeout)
+		return
+	}
+	if err != nil {
+		dj.lastError = err
+		return
+	}
+	if status != 0 {
+		dj.lastError = fmt.Errorf("command: %s in container: %s exited with status %d", strings.Join(dj.command, " "), dj.ID, status)
+	}
+}
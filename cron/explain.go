@@ -0,0 +1,247 @@
+package cron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/robfig/cron.v2"
+)
+
+// starBit mirrors the bit robfig/cron sets when a field was given as * or ?
+const starBit = 1 << 63
+
+type fieldBounds struct {
+	min, max uint
+	unit     string
+	plural   string
+	names    []string
+}
+
+var (
+	secondBounds = fieldBounds{0, 59, "second", "seconds", nil}
+	minuteBounds = fieldBounds{0, 59, "minute", "minutes", nil}
+	hourBounds   = fieldBounds{0, 23, "hour", "hours", nil}
+	domBounds    = fieldBounds{1, 31, "day-of-month", "days of the month", nil}
+	monthBounds  = fieldBounds{1, 12, "month", "months", []string{
+		"", "January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December",
+	}}
+	dowBounds = fieldBounds{0, 6, "day-of-week", "days of the week", []string{
+		"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday",
+	}}
+)
+
+// ParseSchedule parses a cron expression with the same parser the daemon uses.
+// If location is set and the expression has no TZ= prefix, it is applied to the schedule.
+func ParseSchedule(spec, location string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if location != "" && !strings.HasPrefix(spec, "TZ=") {
+		if _, err := time.LoadLocation(location); err != nil {
+			return nil, err
+		}
+		spec = fmt.Sprintf("TZ=%s %s", location, spec)
+	}
+
+	return cron.Parse(spec)
+}
+
+// NextRuns returns the next n fire times of the schedule after from
+func NextRuns(schedule cron.Schedule, from time.Time, n int) []time.Time {
+	runs := []time.Time{}
+	for i := 0; i < n; i++ {
+		from = schedule.Next(from)
+		if from.IsZero() {
+			break
+		}
+		runs = append(runs, from)
+	}
+	return runs
+}
+
+// ScheduleLocation returns the time zone a schedule fires in, given with TZ=
+// or --timezone, the local one by default
+func ScheduleLocation(schedule cron.Schedule) *time.Location {
+	if spec, ok := schedule.(*cron.SpecSchedule); ok && spec.Location != nil {
+		return spec.Location
+	}
+	return time.Local
+}
+
+// Explain returns a plain-English description of a parsed schedule
+func Explain(schedule cron.Schedule) string {
+	switch s := schedule.(type) {
+	case cron.ConstantDelaySchedule:
+		return fmt.Sprintf("every %s, counted from when the daemon picked up the job", s.Delay)
+	case *cron.SpecSchedule:
+		return explainSpec(s)
+	}
+
+	return fmt.Sprintf("custom schedule %T", schedule)
+}
+
+func explainSpec(s *cron.SpecSchedule) string {
+	description := explainTime(s)
+
+	domAll := isAll(s.Dom, domBounds)
+	dowAll := isAll(s.Dow, dowBounds)
+	if s.Dom&starBit == 0 && s.Dow&starBit == 0 {
+		// robfig/cron fires when either day field matches, unlike Quartz which forbids setting both
+		if !domAll && !dowAll {
+			description += fmt.Sprintf(", on %s or on %s", describeField(s.Dom, domBounds), describeField(s.Dow, dowBounds))
+		}
+	} else {
+		switch {
+		case !domAll && !dowAll:
+			description += fmt.Sprintf(", on %s if it is also %s", describeField(s.Dom, domBounds), describeField(s.Dow, dowBounds))
+		case !domAll:
+			description += ", on " + describeField(s.Dom, domBounds)
+		case !dowAll:
+			description += ", on " + describeField(s.Dow, dowBounds)
+		}
+	}
+
+	if !isAll(s.Month, monthBounds) {
+		description += ", in " + describeField(s.Month, monthBounds)
+	}
+
+	if s.Location != nil && s.Location != time.Local {
+		description += fmt.Sprintf(" (%s)", s.Location)
+	}
+
+	return description
+}
+
+func explainTime(s *cron.SpecSchedule) string {
+	second, minute, hour := values(s.Second, secondBounds), values(s.Minute, minuteBounds), values(s.Hour, hourBounds)
+	if len(second) == 1 && len(minute) == 1 && len(hour) == 1 {
+		return fmt.Sprintf("at %02d:%02d:%02d", hour[0], minute[0], second[0])
+	}
+
+	fields := []struct {
+		bits   uint64
+		bounds fieldBounds
+	}{
+		{s.Second, secondBounds},
+		{s.Minute, minuteBounds},
+		{s.Hour, hourBounds},
+	}
+
+	// Describe from the smallest unit up, stopping at the first unrestricted
+	// field once every larger unit is unrestricted as well
+	parts := []string{}
+	for i, field := range fields {
+		if isAll(field.bits, field.bounds) {
+			parts = append(parts, "every "+field.bounds.unit)
+			restricted := false
+			for _, larger := range fields[i+1:] {
+				if !isAll(larger.bits, larger.bounds) {
+					restricted = true
+				}
+			}
+			if !restricted {
+				break
+			}
+			continue
+		}
+		phrase := describeField(field.bits, field.bounds)
+		if i == 0 {
+			phrase = "at " + phrase
+		}
+		parts = append(parts, phrase)
+	}
+
+	return strings.Join(parts, " of ")
+}
+
+// describeField renders the values set in a field, e.g. "minute 5", "hours 9 through 17"
+// or "every 15th minute"
+func describeField(bits uint64, b fieldBounds) string {
+	vals := values(bits, b)
+	if len(vals) == 1 && b.names != nil {
+		return b.name(vals[0])
+	}
+	if len(vals) == 1 {
+		return fmt.Sprintf("%s %s", b.unit, b.name(vals[0]))
+	}
+
+	if step := stepOf(vals); step > 1 && b.names == nil && vals[0] == b.min && vals[len(vals)-1]+step > b.max {
+		return fmt.Sprintf("every %s %s", ordinal(step), b.unit)
+	}
+
+	ranges := []string{}
+	for i := 0; i < len(vals); {
+		j := i
+		for j+1 < len(vals) && vals[j+1] == vals[j]+1 {
+			j++
+		}
+		switch {
+		case j-i >= 2:
+			ranges = append(ranges, fmt.Sprintf("%s through %s", b.name(vals[i]), b.name(vals[j])))
+		case j-i == 1:
+			ranges = append(ranges, b.name(vals[i]), b.name(vals[j]))
+		default:
+			ranges = append(ranges, b.name(vals[i]))
+		}
+		i = j + 1
+	}
+
+	prefix := b.plural + " "
+	if b.names != nil {
+		prefix = ""
+	}
+
+	if len(ranges) == 1 {
+		return prefix + ranges[0]
+	}
+	return prefix + strings.Join(ranges[:len(ranges)-1], ", ") + " and " + ranges[len(ranges)-1]
+}
+
+func (b fieldBounds) name(v uint) string {
+	if b.names != nil {
+		return b.names[v]
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+func values(bits uint64, b fieldBounds) []uint {
+	vals := []uint{}
+	for i := b.min; i <= b.max; i++ {
+		if bits&(1<<i) > 0 {
+			vals = append(vals, i)
+		}
+	}
+	return vals
+}
+
+func isAll(bits uint64, b fieldBounds) bool {
+	return uint(len(values(bits, b))) == b.max-b.min+1
+}
+
+// stepOf returns the common difference of vals, or 0 if they are not evenly spaced
+func stepOf(vals []uint) uint {
+	if len(vals) < 2 {
+		return 0
+	}
+	step := vals[1] - vals[0]
+	for i := 2; i < len(vals); i++ {
+		if vals[i]-vals[i-1] != step {
+			return 0
+		}
+	}
+	return step
+}
+
+func ordinal(n uint) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,119 @@
+package cron
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// HashSeed returns the seed of the H fields of a job, its container name qualified
+// the way jobs are keyed: <endpoint>:<container>/<job>, without the parts of an
+// unnamed endpoint or of the default job
+func HashSeed(endpoint, container, job string) string {
+	if endpoint != "" {
+		container = endpoint + ":" + container
+	}
+	return jobKey(container, job)
+}
+
+// ExpandHashedSpec replaces Jenkins-style H fields in a cron expression with a value
+// derived from seed, usually the container name. H picks a stable value within the
+// field's range, H(a-b) within a-b, and H/n or H(a-b)/n a stable offset stepping by n.
+// This spreads identical schedules of many containers without making them random.
+func ExpandHashedSpec(spec, seed string) (string, error) {
+	prefix := ""
+	if strings.HasPrefix(spec, "TZ=") {
+		i := strings.Index(spec, " ")
+		if i < 0 {
+			return spec, nil
+		}
+		prefix, spec = spec[:i+1], strings.TrimSpace(spec[i:])
+	}
+
+	if strings.HasPrefix(spec, "@") || !strings.Contains(spec, "H") {
+		return prefix + spec, nil
+	}
+
+	fields := strings.Fields(spec)
+	bounds := []fieldBounds{secondBounds, minuteBounds, hourBounds, domBounds, monthBounds, dowBounds}
+	if len(fields) == 5 {
+		bounds = bounds[1:]
+	}
+	if len(fields) != len(bounds) {
+		return "", fmt.Errorf("Expected 5 or 6 fields, found %d: %s", len(fields), spec)
+	}
+
+	for i, field := range fields {
+		if !strings.HasPrefix(field, "H") {
+			continue
+		}
+		expanded, err := expandHashedField(field, bounds[i], hashOf(seed, bounds[i].unit))
+		if err != nil {
+			return "", err
+		}
+		fields[i] = expanded
+	}
+
+	return prefix + strings.Join(fields, " "), nil
+}
+
+func expandHashedField(field string, b fieldBounds, hash uint32) (string, error) {
+	min, max := b.min, b.max
+	if b.unit == domBounds.unit {
+		// Days past the 28th do not exist in every month
+		max = 28
+	}
+
+	rest := field[1:]
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end < 0 {
+			return "", fmt.Errorf("Unterminated range in %s", field)
+		}
+		lowHigh := strings.Split(rest[1:end], "-")
+		if len(lowHigh) != 2 {
+			return "", fmt.Errorf("Invalid range in %s", field)
+		}
+		low, err := strconv.Atoi(lowHigh[0])
+		if err != nil {
+			return "", fmt.Errorf("Invalid range in %s: %s", field, err)
+		}
+		high, err := strconv.Atoi(lowHigh[1])
+		if err != nil {
+			return "", fmt.Errorf("Invalid range in %s: %s", field, err)
+		}
+		if low > high {
+			return "", fmt.Errorf("Beginning of range beyond end of range in %s", field)
+		}
+		if low < int(b.min) || high > int(b.max) {
+			return "", fmt.Errorf("Range in %s outside of %d-%d", field, b.min, b.max)
+		}
+		min, max = uint(low), uint(high)
+		rest = rest[end+1:]
+	}
+
+	switch {
+	case rest == "":
+		return strconv.Itoa(int(min + uint(hash)%(max-min+1))), nil
+	case strings.HasPrefix(rest, "/"):
+		step, err := strconv.Atoi(rest[1:])
+		if err != nil || step <= 0 {
+			return "", fmt.Errorf("Invalid step in %s", field)
+		}
+		span := max - min + 1
+		if uint(step) < span {
+			span = uint(step)
+		}
+		start := min + uint(hash)%span
+		return fmt.Sprintf("%d-%d/%d", start, max, step), nil
+	}
+
+	return "", fmt.Errorf("Invalid hashed field: %s", field)
+}
+
+func hashOf(seed, field string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(seed + "/" + field))
+	return h.Sum32()
+}
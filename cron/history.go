@@ -0,0 +1,63 @@
+package cron
+
+import "time"
+
+const (
+	// TriggerSchedule marks runs started by the cron schedule
+	TriggerSchedule = "schedule"
+	// TriggerManual marks runs started through the control socket
+	TriggerManual = "manual"
+	// TriggerCatchup marks runs making up for a fire time missed while the daemon was down
+	TriggerCatchup = "catchup"
+	// TriggerChain marks runs started because the job they are chained after finished
+	TriggerChain = "chain"
+
+	maxHistory = 20
+)
+
+// RunRecord describes a single execution of a job. Run numbers every execution
+// of the job, Attempt is 0 for the initial run and counts up for each retry.
+type RunRecord struct {
+	Run       int       `json:"run"`
+	Action    string    `json:"action"`
+	Trigger   string    `json:"trigger"`
+	Attempt   int       `json:"attempt"`
+	Scheduled time.Time `json:"scheduled"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// History returns the most recent runs of the job, oldest first
+func (dj *DockerJob) History() []RunRecord {
+	dj.historyLock.Lock()
+	defer dj.historyLock.Unlock()
+
+	history := make([]RunRecord, len(dj.history))
+	copy(history, dj.history)
+	return history
+}
+
+func (dj *DockerJob) addRecord(record RunRecord) {
+	dj.historyLock.Lock()
+	defer dj.historyLock.Unlock()
+
+	dj.history = append(dj.history, record)
+	if len(dj.history) > maxHistory {
+		dj.history = dj.history[len(dj.history)-maxHistory:]
+	}
+}
+
+// nextRun returns the number of a new run of the job, counting across daemon restarts
+func (dj *DockerJob) nextRun() int {
+	if dj.state == nil {
+		return 0
+	}
+
+	run := 0
+	dj.state.Update(dj.Key(), func(state *JobState) {
+		state.Runs++
+		run = state.Runs
+	})
+	return run
+}
@@ -0,0 +1,152 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// httpAction holds the cron.http.* settings of the http action
+type httpAction struct {
+	Method       string
+	Port         int
+	Path         string
+	Network      string
+	ExpectStatus int
+	Timeout      time.Duration
+}
+
+func parseHTTPAction(labels map[string]string) (*httpAction, error) {
+	action := &httpAction{
+		Method:  strings.ToUpper(labels["cron.http.method"]),
+		Path:    labels["cron.http.path"],
+		Network: labels["cron.http.network"],
+		Timeout: defaultHTTPTimeout,
+	}
+
+	if action.Method == "" {
+		action.Method = http.MethodGet
+	}
+
+	if !strings.HasPrefix(action.Path, "/") {
+		action.Path = "/" + action.Path
+	}
+
+	port, err := strconv.Atoi(labels["cron.http.port"])
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("cron.http.port: %q is not a valid port", labels["cron.http.port"])
+	}
+	action.Port = port
+
+	if value, ok := labels["cron.http.expect_status"]; ok {
+		status, err := strconv.Atoi(value)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("cron.http.expect_status: %q is not a valid HTTP status", value)
+		}
+		action.ExpectStatus = status
+	}
+
+	if value, ok := labels["cron.http.timeout"]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("cron.http.timeout: %q is not a valid duration", value)
+		}
+		action.Timeout = timeout
+	}
+
+	return action, nil
+}
+
+// containerIP returns the address of the container on the named network, or on the
+// first one (by name) when network is empty
+func containerIP(container types.ContainerJSON, network string) (string, error) {
+	if container.HostConfig != nil && container.HostConfig.NetworkMode.IsHost() {
+		return "127.0.0.1", nil
+	}
+
+	if container.NetworkSettings == nil {
+		return "", fmt.Errorf("container: %s has no network settings", container.ID)
+	}
+
+	if network != "" {
+		settings, ok := container.NetworkSettings.Networks[network]
+		if !ok || settings.IPAddress == "" {
+			return "", fmt.Errorf("container: %s has no address on network: %s", container.ID, network)
+		}
+		return settings.IPAddress, nil
+	}
+
+	names := []string{}
+	for name := range container.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if settings := container.NetworkSettings.Networks[name]; settings != nil && settings.IPAddress != "" {
+			return settings.IPAddress, nil
+		}
+	}
+
+	if container.NetworkSettings.IPAddress != "" {
+		return container.NetworkSettings.IPAddress, nil
+	}
+	return "", fmt.Errorf("container: %s has no IP address", container.ID)
+}
+
+// callHTTP implements the http action: it sends a request to the container's own
+// address and fails unless the expected status comes back in time
+func (dj *DockerJob) callHTTP() {
+	var client *client.Client
+	client, dj.lastError = dj.dockerClient()
+	if dj.Err() != nil {
+		return
+	}
+
+	container, err := client.ContainerInspect(context.Background(), dj.ID)
+	if err != nil {
+		dj.lastError = err
+		return
+	}
+
+	ip, err := containerIP(container, dj.http.Network)
+	if err != nil {
+		dj.lastError = err
+		return
+	}
+
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(ip, strconv.Itoa(dj.http.Port)), dj.http.Path)
+	req, err := http.NewRequest(dj.http.Method, url, nil)
+	if err != nil {
+		dj.lastError = err
+		return
+	}
+
+	resp, err := (&http.Client{Timeout: dj.http.Timeout}).Do(req)
+	if err != nil {
+		dj.lastError = err
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, int64(dj.outputSize())))
+	dj.lastOutput = string(body)
+
+	switch {
+	case dj.http.ExpectStatus != 0 && resp.StatusCode != dj.http.ExpectStatus:
+		dj.lastError = fmt.Errorf("%s %s returned %s, expected %d", dj.http.Method, url, resp.Status, dj.http.ExpectStatus)
+	case dj.http.ExpectStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299):
+		dj.lastError = fmt.Errorf("%s %s returned %s", dj.http.Method, url, resp.Status)
+	}
+}
@@ -0,0 +1,19 @@
+package cron
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// waitJitter delays a scheduled run by a random offset of up to cron.jitter
+func (dj *DockerJob) waitJitter() {
+	if dj.jitter <= 0 {
+		return
+	}
+
+	delay := time.Duration(rand.Int63n(int64(dj.jitter)))
+	logrus.Debugf("Delaying: %s on %s by %s", dj.Action, dj.ID, delay)
+	time.Sleep(delay)
+}
@@ -0,0 +1,58 @@
+package cron
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// validityReason returns why t is outside of cron.not_before and cron.not_after, or "" if it is not
+func (dj *DockerJob) validityReason(t time.Time) string {
+	switch {
+	case !dj.notBefore.IsZero() && t.Before(dj.notBefore):
+		return fmt.Sprintf("not valid before %s", dj.notBefore)
+	case !dj.notAfter.IsZero() && t.After(dj.notAfter):
+		return fmt.Sprintf("not valid after %s", dj.notAfter)
+	}
+	return ""
+}
+
+func (dj *DockerJob) countSuccess() {
+	if dj.maxRuns <= 0 || dj.state == nil {
+		return
+	}
+	dj.state.Update(dj.Key(), func(state *JobState) {
+		state.SuccessfulRuns++
+	})
+}
+
+// done reports whether the job will never run again after t: its schedule is
+// exhausted or past cron.not_after, or it reached cron.max_runs
+func (dj *DockerJob) done(t time.Time) bool {
+	if dj.maxRuns > 0 && dj.state != nil && dj.state.Get(dj.Key()).SuccessfulRuns >= dj.maxRuns {
+		return true
+	}
+
+	// Jobs scheduled after finishing get their next run once the current one is over
+	if dj.delayAfterFinish > 0 {
+		return !dj.notAfter.IsZero() && t.Add(dj.delayAfterFinish).After(dj.notAfter)
+	}
+
+	if dj.schedule == nil {
+		return false
+	}
+	next := dj.schedule.Next(t)
+	return next.IsZero() || (!dj.notAfter.IsZero() && next.After(dj.notAfter))
+}
+
+// retireIfDone removes a job that will never run again from the crontab
+func (dj *DockerJob) retireIfDone() bool {
+	if dj.retire == nil || !dj.done(time.Now()) {
+		return false
+	}
+
+	logrus.Infof("Retiring: %s, it has no runs left", dj.ID)
+	go dj.retire()
+	return true
+}
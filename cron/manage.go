@@ -0,0 +1,198 @@
+package cron
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// JobStatus is a snapshot of a job as reported to clients
+type JobStatus struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Endpoint string            `json:"endpoint,omitempty"`
+	Action   string            `json:"action"`
+	Schedule string            `json:"schedule"`
+	Active   bool              `json:"active"`
+	Paused   bool              `json:"paused"`
+	Next     time.Time         `json:"next"`
+	Prev     time.Time         `json:"prev"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// ListJobs returns the status of every job, sorted by name
+func (ct *Crontab) ListJobs() []JobStatus {
+	ct.jobsLock.RLock()
+	defer ct.jobsLock.RUnlock()
+
+	jobs := []JobStatus{}
+	for _, jobEntry := range ct.jobs {
+		jobs = append(jobs, ct.jobStatus(jobEntry))
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].Name == jobs[j].Name {
+			return jobs[i].ID < jobs[j].ID
+		}
+		return jobs[i].Name < jobs[j].Name
+	})
+
+	return jobs
+}
+
+// GetJob looks up a job by container name, container ID or unique ID prefix.
+// Named jobs are referenced as <container>/<job>, and jobs of containers of a
+// named Docker endpoint as <endpoint>:<container>.
+func (ct *Crontab) GetJob(ref string) (*JobEntry, error) {
+	ct.jobsLock.RLock()
+	defer ct.jobsLock.RUnlock()
+
+	ref = strings.TrimPrefix(ref, "/")
+	if jobEntry, ok := ct.jobs[ref]; ok {
+		return jobEntry, nil
+	}
+
+	var found *JobEntry
+	for id, jobEntry := range ct.jobs {
+		if jobEntry.Job.FullName() == ref {
+			return jobEntry, nil
+		}
+		if strings.HasPrefix(id, ref) {
+			if found != nil {
+				return nil, fmt.Errorf("job reference: %s is ambiguous", ref)
+			}
+			found = jobEntry
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("no job found for: %s", ref)
+	}
+	return found, nil
+}
+
+// JobStatus returns the status of a single job
+func (ct *Crontab) JobStatus(ref string) (JobStatus, error) {
+	jobEntry, err := ct.GetJob(ref)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	status := ct.jobStatus(jobEntry)
+	status.Labels = jobEntry.Job.Labels
+	return status, nil
+}
+
+// TriggerJob runs a job in the background outside of its schedule
+func (ct *Crontab) TriggerJob(ref string) error {
+	jobEntry, err := ct.GetJob(ref)
+	if err != nil {
+		return err
+	}
+
+	go jobEntry.Job.Trigger()
+	return nil
+}
+
+// JobOutput returns the captured output of run number run of a job, or of its latest run when run is 0
+func (ct *Crontab) JobOutput(ref string, run int) (RunOutput, error) {
+	jobEntry, err := ct.GetJob(ref)
+	if err != nil {
+		return RunOutput{}, err
+	}
+
+	return ct.outputs.Get(jobEntry.Job.Key(), run)
+}
+
+// Audit returns up to limit of the latest scheduling decisions about a job, or
+// about every job when ref is empty. Jobs that were removed can still be
+// referenced by their full ID.
+func (ct *Crontab) Audit(ref string, limit int) []AuditRecord {
+	key := ref
+	if ref != "" {
+		if jobEntry, err := ct.GetJob(ref); err == nil {
+			key = jobEntry.Job.Key()
+		}
+	}
+	return ct.auditLog.Recent(key, limit)
+}
+
+// PauseJob skips the scheduled runs of a single job
+func (ct *Crontab) PauseJob(ref string) error {
+	jobEntry, err := ct.GetJob(ref)
+	if err != nil {
+		return err
+	}
+
+	jobEntry.Job.Pause()
+	return nil
+}
+
+// ResumeJob reverts PauseJob
+func (ct *Crontab) ResumeJob(ref string) error {
+	jobEntry, err := ct.GetJob(ref)
+	if err != nil {
+		return err
+	}
+
+	jobEntry.Job.Resume()
+	return nil
+}
+
+// Pause stops the cron runner, no job will fire until Resume is called
+func (ct *Crontab) Pause() {
+	ct.jobsLock.Lock()
+	defer ct.jobsLock.Unlock()
+
+	if !ct.paused {
+		logrus.Info("Pausing Cron")
+		ct.cronRunner.Stop()
+		ct.paused = true
+		ct.pausedAt = time.Now()
+	}
+}
+
+// Resume restarts the cron runner after Pause
+func (ct *Crontab) Resume() {
+	ct.jobsLock.Lock()
+	defer ct.jobsLock.Unlock()
+
+	if ct.paused {
+		logrus.Info("Resuming Cron")
+		ct.cronRunner.Start()
+		ct.paused = false
+		for _, jobEntry := range ct.jobs {
+			jobEntry.Job.skipPaused(ct.pausedAt, time.Now())
+		}
+	}
+}
+
+// Paused reports whether the whole cron runner is paused
+func (ct *Crontab) Paused() bool {
+	ct.jobsLock.RLock()
+	defer ct.jobsLock.RUnlock()
+	return ct.paused
+}
+
+func (ct *Crontab) jobStatus(jobEntry *JobEntry) JobStatus {
+	entry := ct.cronRunner.Entry(jobEntry.CronID)
+	if history := jobEntry.Job.History(); entry.Prev.IsZero() && len(history) > 0 {
+		// Rescheduled jobs get a fresh cron entry, fall back to the last recorded run
+		entry.Prev = history[len(history)-1].Start
+	}
+
+	return JobStatus{
+		ID:       jobEntry.Job.Key(),
+		Name:     jobEntry.Job.FullName(),
+		Endpoint: jobEntry.Job.Endpoint(),
+		Action:   jobEntry.Job.Action,
+		Schedule: jobEntry.Job.Schedule,
+		Active:   jobEntry.Job.IsActive(),
+		Paused:   jobEntry.Job.IsPaused(),
+		Next:     entry.Next,
+		Prev:     entry.Prev,
+	}
+}
@@ -0,0 +1,48 @@
+package cron
+
+import (
+	"fmt"
+
+	"github.com/rancher/container-crontab/notify"
+)
+
+// Values of cron.notify
+const (
+	NotifyFailure = "failure"
+	NotifyAlways  = "always"
+	NotifyNever   = "never"
+)
+
+func parseNotify(value string) (string, error) {
+	switch value {
+	case NotifyFailure, NotifyAlways, NotifyNever:
+		return value, nil
+	}
+	return NotifyFailure, fmt.Errorf("Unknown cron.notify: %s, expected %s, %s or %s", value, NotifyFailure, NotifyAlways, NotifyNever)
+}
+
+// notify reports the outcome of a run once it is final, after its last retry
+func (dj *DockerJob) notify(record RunRecord) {
+	if dj.notifier == nil || dj.notifyOn == NotifyNever {
+		return
+	}
+	if record.Error == "" && dj.notifyOn != NotifyAlways {
+		return
+	}
+
+	dj.notifier.Notify(notify.Event{
+		Job:       dj.FullName(),
+		ID:        dj.Key(),
+		Container: dj.Name,
+		Action:    record.Action,
+		Trigger:   record.Trigger,
+		Run:       record.Run,
+		Attempt:   record.Attempt,
+		Scheduled: record.Scheduled,
+		Start:     record.Start,
+		Duration:  record.End.Sub(record.Start).Seconds(),
+		Success:   record.Error == "",
+		Error:     record.Error,
+		Email:     dj.email,
+	})
+}
@@ -0,0 +1,276 @@
+package cron
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// DefaultOutputSize is how much of the end of a run's output is kept by default
+	DefaultOutputSize = 64 * 1024
+	// DefaultOutputKeep is how many runs of each job have their output kept by default
+	DefaultOutputKeep = 10
+)
+
+// RunOutput is the captured stdout and stderr of a run
+type RunOutput struct {
+	Run    int    `json:"run"`
+	Output string `json:"output"`
+}
+
+// OutputStore keeps the output of the last runs of every job, in files
+// below dir/<job key>/ or in memory when dir is empty
+type OutputStore struct {
+	dir     string
+	maxSize int
+	keep    int
+	memory  map[string]map[int]string
+	lock    sync.Mutex
+}
+
+// NewOutputStore returns a store keeping the last maxSize bytes of the output
+// of the last keep runs of each job
+func NewOutputStore(dir string, maxSize, keep int) *OutputStore {
+	if maxSize <= 0 {
+		maxSize = DefaultOutputSize
+	}
+	if keep <= 0 {
+		keep = DefaultOutputKeep
+	}
+	return &OutputStore{
+		dir:     dir,
+		maxSize: maxSize,
+		keep:    keep,
+		memory:  map[string]map[int]string{},
+	}
+}
+
+// Save records the output of run number run of a job and drops the output of runs past the retention
+func (s *OutputStore) Save(key string, run int, output string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.dir == "" {
+		runs, ok := s.memory[key]
+		if !ok {
+			runs = map[int]string{}
+			s.memory[key] = runs
+		}
+		runs[run] = output
+		for _, old := range s.expired(memoryRuns(runs)) {
+			delete(runs, old)
+		}
+		return
+	}
+
+	dir := filepath.Join(s.dir, key)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		logrus.Errorf("Error creating output directory: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.log", run)), []byte(output), 0600); err != nil {
+		logrus.Errorf("Error writing output of: %s: %s", key, err)
+		return
+	}
+	for _, old := range s.expired(s.fileRuns(key)) {
+		os.Remove(filepath.Join(dir, fmt.Sprintf("%d.log", old)))
+	}
+}
+
+// Get returns the output of run number run of a job, or of its latest run when run is 0
+func (s *OutputStore) Get(key string, run int) (RunOutput, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var runs []int
+	if s.dir == "" {
+		runs = memoryRuns(s.memory[key])
+	} else {
+		runs = s.fileRuns(key)
+	}
+
+	if run == 0 && len(runs) > 0 {
+		run = runs[len(runs)-1]
+	}
+
+	found := false
+	for _, r := range runs {
+		if r == run {
+			found = true
+		}
+	}
+	if !found {
+		if run == 0 {
+			return RunOutput{}, fmt.Errorf("no output recorded for job: %s", key)
+		}
+		return RunOutput{}, fmt.Errorf("no output recorded for run %d of job: %s", run, key)
+	}
+
+	if s.dir == "" {
+		return RunOutput{Run: run, Output: s.memory[key][run]}, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, key, fmt.Sprintf("%d.log", run)))
+	if err != nil {
+		return RunOutput{}, err
+	}
+	return RunOutput{Run: run, Output: string(data)}, nil
+}
+
+// RemoveContainer forgets the output of all jobs of a container
+func (s *OutputStore) RemoveContainer(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key := range s.memory {
+		if key == id || strings.HasPrefix(key, id+"/") {
+			delete(s.memory, key)
+		}
+	}
+
+	if s.dir != "" {
+		if err := os.RemoveAll(filepath.Join(s.dir, id)); err != nil {
+			logrus.Errorf("Error removing output of: %s: %s", id, err)
+		}
+	}
+}
+
+// expired returns the runs, sorted in ascending order, that are past the retention
+func (s *OutputStore) expired(runs []int) []int {
+	if len(runs) <= s.keep {
+		return nil
+	}
+	return runs[:len(runs)-s.keep]
+}
+
+// fileRuns returns the numbers of the runs of a job with an output file, in ascending order
+func (s *OutputStore) fileRuns(key string) []int {
+	files, err := ioutil.ReadDir(filepath.Join(s.dir, key))
+	if err != nil {
+		return nil
+	}
+
+	runs := []int{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".log") {
+			continue
+		}
+		if run, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".log")); err == nil {
+			runs = append(runs, run)
+		}
+	}
+	sort.Ints(runs)
+	return runs
+}
+
+func memoryRuns(outputs map[int]string) []int {
+	runs := []int{}
+	for run := range outputs {
+		runs = append(runs, run)
+	}
+	sort.Ints(runs)
+	return runs
+}
+
+// tailBuffer is a writer keeping only the last max bytes written to it
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
+
+// outputSize is how much of the end of a run's output the job keeps
+func (dj *DockerJob) outputSize() int {
+	if dj.outputs != nil {
+		return dj.outputs.maxSize
+	}
+	return DefaultOutputSize
+}
+
+// containerOutput returns the tail of the logs a container wrote since a
+// point in time, or all of them when since is zero
+func (dj *DockerJob) containerOutput(client *client.Client, id string, tty bool, since time.Time) string {
+	options := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	}
+	if !since.IsZero() {
+		options.Since = since.Format(time.RFC3339Nano)
+	}
+
+	logs, err := client.ContainerLogs(context.Background(), id, options)
+	if err != nil {
+		logrus.Errorf("Error reading logs of: %s: %s", id, err)
+		return ""
+	}
+	defer logs.Close()
+
+	out := newTailBuffer(dj.outputSize())
+	if tty {
+		_, err = io.Copy(out, logs)
+	} else {
+		err = demuxLogs(out, logs)
+	}
+	if err != nil {
+		logrus.Errorf("Error reading logs of: %s: %s", id, err)
+	}
+
+	return out.String()
+}
+
+// demuxLogs strips the 8 byte frame headers Docker puts in front of every chunk
+// of stdout and stderr when the container has no TTY
+func demuxLogs(w io.Writer, r io.Reader) error {
+	var header [8]byte
+
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// saveOutput stores the output of a run once it is over
+func (dj *DockerJob) saveOutput(run int) {
+	if dj.outputs == nil || run == 0 || dj.lastOutput == "" {
+		return
+	}
+	dj.outputs.Save(dj.Key(), run, dj.lastOutput)
+}
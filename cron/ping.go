@@ -0,0 +1,62 @@
+package cron
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+var pingClient = &http.Client{Timeout: 10 * time.Second}
+
+// parsePingURL checks cron.ping_url is an absolute http(s) URL
+func parsePingURL(value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("expected an http or https URL, got: %s", value)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+// pingStart tells the monitor at cron.ping_url that a run began
+func (dj *DockerJob) pingStart() {
+	if dj.pingURL == "" {
+		return
+	}
+	dj.ping(dj.pingURL+"/start", "")
+}
+
+// pingResult reports the final outcome of a run to the monitor at cron.ping_url,
+// with the error and the captured output in the body
+func (dj *DockerJob) pingResult() {
+	if dj.pingURL == "" {
+		return
+	}
+
+	body := dj.lastOutput
+	if dj.Err() != nil {
+		body = dj.Err().Error() + "\n\n" + body
+		dj.ping(dj.pingURL+"/fail", body)
+		return
+	}
+	dj.ping(dj.pingURL, body)
+}
+
+func (dj *DockerJob) ping(pingURL, body string) {
+	resp, err := pingClient.Post(pingURL, "text/plain; charset=utf-8", strings.NewReader(body))
+	if err != nil {
+		logrus.Errorf("Error pinging %s for %s: %s", pingURL, dj.Key(), err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		logrus.Errorf("Error pinging %s for %s: unexpected response: %s", pingURL, dj.Key(), resp.Status)
+	}
+}
@@ -0,0 +1,67 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types/events"
+)
+
+// podmanActions maps the container actions of Podman's event stream to Docker's
+var podmanActions = map[string]string{
+	"died":   "die",
+	"remove": "destroy",
+}
+
+// containerEvent converts an event of the daemon. Podman's Docker-compatible
+// event stream may only set the ID on the actor and the action in the status,
+// uses its own action names and, depending on its version, leaves the container
+// labels out of the attributes; they are then read from the container.
+func (r *dockerRuntime) containerEvent(ctx context.Context, msg events.Message) ContainerEvent {
+	id := msg.ID
+	if id == "" {
+		id = msg.Actor.ID
+	}
+
+	action := msg.Action
+	if action == "" {
+		action = msg.Status
+	}
+	if docker, ok := podmanActions[action]; ok {
+		action = docker
+	}
+
+	attributes := msg.Actor.Attributes
+	if (action == "start" || action == "create") && !HasSchedule(attributes) && r.endpoint.Podman() {
+		attributes = r.containerAttributes(ctx, id, attributes)
+	}
+	event := ContainerEvent{ID: id, Action: action, Attributes: attributes}
+	if msg.TimeNano != 0 {
+		event.Time = time.Unix(0, msg.TimeNano)
+	} else if msg.Time != 0 {
+		event.Time = time.Unix(msg.Time, 0)
+	}
+	return event
+}
+
+// containerAttributes adds the labels and name of a container to the attributes of one of its events
+func (r *dockerRuntime) containerAttributes(ctx context.Context, id string, attributes map[string]string) map[string]string {
+	container, err := r.Inspect(ctx, id)
+	if err != nil {
+		logrus.Debugf("Error inspecting container: %s: %s", id, err)
+		return attributes
+	}
+
+	merged := map[string]string{}
+	for key, value := range container.Labels {
+		merged[key] = value
+	}
+	for key, value := range attributes {
+		merged[key] = value
+	}
+	if merged["name"] == "" {
+		merged["name"] = container.Name
+	}
+	return merged
+}
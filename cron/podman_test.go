@@ -0,0 +1,152 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// podmanServer answers the pings and container inspections of a daemon
+// sending headers on its pings
+type podmanServer struct {
+	*httptest.Server
+	inspections int32
+}
+
+func startPodman(t *testing.T, headers map[string]string) *podmanServer {
+	s := &podmanServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			for key, value := range headers {
+				w.Header().Set(key, value)
+			}
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/json"):
+			atomic.AddInt32(&s.inspections, 1)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Id":     "abc",
+				"Name":   "/backup",
+				"Config": map[string]interface{}{"Labels": map[string]string{"cron.schedule": "@hourly"}},
+				"State":  map[string]interface{}{"Running": true},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+func (s *podmanServer) endpoint() *Endpoint {
+	return &Endpoint{Name: "test", Host: "tcp://" + strings.TrimPrefix(s.URL, "http://")}
+}
+
+func TestPingDetectsPodman(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		headers map[string]string
+		version string
+		podman  bool
+	}{
+		{name: "docker", headers: map[string]string{"API-Version": "1.41", "Server": "Docker/20.10.7 (linux)"}, version: "1.26"},
+		{name: "libpod header", headers: map[string]string{"API-Version": "1.40", "Libpod-API-Version": "4.3.1"}, version: "1.26", podman: true},
+		{name: "libpod server", headers: map[string]string{"API-Version": "1.40", "Server": "Libpod/4.3.1 (linux)"}, version: "1.26", podman: true},
+		{name: "old daemon", headers: map[string]string{}, version: minAPIVersion},
+	} {
+		server := startPodman(t, test.headers)
+		endpoint := server.endpoint()
+
+		dClient, err := endpoint.Client()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if version := dClient.ClientVersion(); version != test.version {
+			t.Errorf("%s: expected API version %s, got %s", test.name, test.version, version)
+		}
+		if endpoint.Podman() != test.podman {
+			t.Errorf("%s: expected Podman %t, got %t", test.name, test.podman, endpoint.Podman())
+		}
+		if again, _ := endpoint.Client(); again != dClient {
+			t.Errorf("%s: expected the client to be reused", test.name)
+		}
+		server.Close()
+	}
+}
+
+func TestPodmanContainerEvent(t *testing.T) {
+	labels := map[string]string{"cron.schedule": "@hourly"}
+
+	for _, test := range []struct {
+		name       string
+		podman     bool
+		msg        events.Message
+		action     string
+		attributes map[string]string
+		inspected  bool
+	}{
+		{
+			name:       "died",
+			podman:     true,
+			msg:        events.Message{Status: "died", Actor: events.Actor{ID: "abc", Attributes: labels}},
+			action:     "die",
+			attributes: labels,
+		},
+		{
+			name:       "remove",
+			podman:     true,
+			msg:        events.Message{Action: "remove", ID: "abc", Actor: events.Actor{Attributes: labels}},
+			action:     "destroy",
+			attributes: labels,
+		},
+		{
+			name:       "labels from inspect",
+			podman:     true,
+			msg:        events.Message{Action: "start", Actor: events.Actor{ID: "abc", Attributes: map[string]string{"image": "alpine"}}},
+			action:     "start",
+			attributes: map[string]string{"cron.schedule": "@hourly", "image": "alpine", "name": "backup"},
+			inspected:  true,
+		},
+		{
+			name:       "labels in event",
+			podman:     true,
+			msg:        events.Message{Action: "start", Actor: events.Actor{ID: "abc", Attributes: labels}},
+			action:     "start",
+			attributes: labels,
+		},
+		{
+			name:       "docker",
+			msg:        events.Message{Action: "start", Actor: events.Actor{ID: "abc", Attributes: map[string]string{"image": "alpine"}}},
+			action:     "start",
+			attributes: map[string]string{"image": "alpine"},
+		},
+	} {
+		headers := map[string]string{"API-Version": "1.40"}
+		if test.podman {
+			headers["Libpod-API-Version"] = "4.3.1"
+		}
+		server := startPodman(t, headers)
+
+		runtime, err := server.endpoint().Connect()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		event := runtime.(*dockerRuntime).containerEvent(context.Background(), test.msg)
+		if event.ID != "abc" || event.Action != test.action {
+			t.Errorf("%s: expected %s of abc, got %s of %s", test.name, test.action, event.Action, event.ID)
+		}
+		if !reflect.DeepEqual(event.Attributes, test.attributes) {
+			t.Errorf("%s: expected attributes %v, got %v", test.name, test.attributes, event.Attributes)
+		}
+		if inspected := atomic.LoadInt32(&server.inspections) > 0; inspected != test.inspected {
+			t.Errorf("%s: expected inspected %t, got %t", test.name, test.inspected, inspected)
+		}
+		server.Close()
+	}
+}
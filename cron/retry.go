@@ -0,0 +1,52 @@
+package cron
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultRetryBackoff = 30 * time.Second
+	maxRetryBackoff     = 24 * time.Hour
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// retryDelay doubles the backoff for every attempt and adds up to 50% of jitter
+func retryDelay(backoff time.Duration, attempt int) time.Duration {
+	delay := backoff
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+func (dj *DockerJob) scheduleRetry(trigger string, scheduled time.Time, attempt int) {
+	delay := retryDelay(dj.retryBackoff, attempt)
+	logrus.Infof("Retrying: %s on %s in %s (attempt %d of %d)", dj.Action, dj.ID, delay, attempt, dj.retries)
+
+	dj.retryLock.Lock()
+	defer dj.retryLock.Unlock()
+
+	dj.retryTimer = time.AfterFunc(delay, func() {
+		dj.run(trigger, scheduled, attempt)
+	})
+}
+
+func (dj *DockerJob) cancelRetry() {
+	dj.retryLock.Lock()
+	defer dj.retryLock.Unlock()
+
+	if dj.retryTimer != nil && dj.retryTimer.Stop() {
+		logrus.Debugf("Cancelled pending retry on %s", dj.ID)
+	}
+	dj.retryTimer = nil
+}
@@ -0,0 +1,112 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Container runtimes an endpoint can run
+const (
+	RuntimeDocker     = "docker"
+	RuntimeKubernetes = "kubernetes"
+)
+
+// Container is a container of a runtime
+type Container struct {
+	ID      string
+	Name    string
+	Labels  map[string]string
+	Running bool
+}
+
+// ContainerEvent is a change in the lifecycle of a container: its create, start,
+// die, stop or destroy action and its attributes, its labels and its name
+type ContainerEvent struct {
+	ID         string
+	Action     string
+	Attributes map[string]string
+	// Time is when the runtime reported the event, zero when it does not tell
+	Time time.Time
+}
+
+// Runtime is a container runtime whose containers are scheduled. The http and
+// run actions and start with cron.wait need the container's networks, config
+// and logs, they use the Docker client and are only supported on Docker endpoints.
+type Runtime interface {
+	// Ping checks the runtime is reachable
+	Ping(ctx context.Context) error
+	// List returns all containers, running or not
+	List(ctx context.Context) ([]Container, error)
+	Inspect(ctx context.Context, id string) (Container, error)
+	Start(ctx context.Context, id string) error
+	// Stop asks the container to stop and kills it after timeout
+	Stop(ctx context.Context, id string, timeout time.Duration) error
+	Restart(ctx context.Context, id string, timeout time.Duration) error
+	Pause(ctx context.Context, id string) error
+	Unpause(ctx context.Context, id string) error
+	// Signal sends a signal, e.g. SIGHUP, to the container
+	Signal(ctx context.Context, id, signal string) error
+	// Exec runs a command in the container, writing its stdout and stderr to
+	// output, and returns its exit code. container names the container of a pod,
+	// runtimes whose containers are not pods ignore it.
+	Exec(ctx context.Context, id, container string, command []string, output io.Writer) (int, error)
+	// Events streams the lifecycle events of the containers until ctx is done,
+	// starting with those that happened since a reconnecting stream left off.
+	// since is zero when the stream first opens.
+	Events(ctx context.Context, since time.Time) (<-chan ContainerEvent, <-chan error)
+	Close() error
+}
+
+// Connect returns the runtime of the endpoint's daemon
+func (e *Endpoint) Connect() (Runtime, error) {
+	if e.runtime() == RuntimeKubernetes {
+		return newKubernetesRuntime(e)
+	}
+
+	dClient, err := e.Client()
+	if err != nil {
+		return nil, err
+	}
+	return &dockerRuntime{client: dClient, endpoint: e}, nil
+}
+
+// runtime returns the container runtime of the endpoint
+func (e *Endpoint) runtime() string {
+	if e == nil || e.Runtime == "" {
+		return RuntimeDocker
+	}
+	return e.Runtime
+}
+
+// runtimeActions are the actions of the runtimes other than Docker, which
+// supports all of them but the ones acting on Kubernetes pods
+var runtimeActions = map[string][]string{
+	RuntimeKubernetes: {"exec", "delete", "rollout-restart"},
+}
+
+// checkRuntime rejects the actions the runtime of the container's endpoint does
+// not support
+func (dj *DockerJob) checkRuntime() error {
+	runtime := dj.endpoint.runtime()
+	actions, ok := runtimeActions[runtime]
+	if !ok {
+		if dj.Action == "delete" || dj.Action == "rollout-restart" {
+			return fmt.Errorf("Unsupported action: %s on %s endpoint: %s, it only applies to Kubernetes pods", dj.Action, runtime, dj.endpoint)
+		}
+		return nil
+	}
+
+	for _, action := range actions {
+		if action == dj.Action {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unsupported action: %s on %s endpoint: %s", dj.Action, runtime, dj.endpoint)
+}
+
+// runtime returns the runtime of the container's endpoint
+func (dj *DockerJob) runtime() (Runtime, error) {
+	return dj.endpoint.Connect()
+}
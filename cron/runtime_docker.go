@@ -0,0 +1,189 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// dockerRuntime is the Runtime of a Docker daemon, or of Podman serving the Docker API
+type dockerRuntime struct {
+	client   *client.Client
+	endpoint *Endpoint
+}
+
+func (r *dockerRuntime) Ping(ctx context.Context) error {
+	_, err := r.client.Ping(ctx)
+	return err
+}
+
+func (r *dockerRuntime) List(ctx context.Context) ([]Container, error) {
+	list, err := r.client.ContainerList(ctx, types.ContainerListOptions{
+		All: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []Container{}
+	for _, container := range list {
+		name := ""
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		containers = append(containers, Container{
+			ID:      container.ID,
+			Name:    name,
+			Labels:  container.Labels,
+			Running: container.State == "running",
+		})
+	}
+	return containers, nil
+}
+
+func (r *dockerRuntime) Inspect(ctx context.Context, id string) (Container, error) {
+	inspect, err := r.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return Container{}, err
+	}
+
+	container := Container{
+		ID:   inspect.ID,
+		Name: strings.TrimPrefix(inspect.Name, "/"),
+	}
+	if inspect.Config != nil {
+		container.Labels = inspect.Config.Labels
+	}
+	if inspect.State != nil {
+		container.Running = inspect.State.Running
+	}
+	return container, nil
+}
+
+func (r *dockerRuntime) Start(ctx context.Context, id string) error {
+	return r.client.ContainerStart(ctx, id, types.ContainerStartOptions{})
+}
+
+func (r *dockerRuntime) Stop(ctx context.Context, id string, timeout time.Duration) error {
+	return r.client.ContainerStop(ctx, id, &timeout)
+}
+
+func (r *dockerRuntime) Restart(ctx context.Context, id string, timeout time.Duration) error {
+	return r.client.ContainerRestart(ctx, id, &timeout)
+}
+
+func (r *dockerRuntime) Pause(ctx context.Context, id string) error {
+	return r.client.ContainerPause(ctx, id)
+}
+
+func (r *dockerRuntime) Unpause(ctx context.Context, id string) error {
+	return r.client.ContainerUnpause(ctx, id)
+}
+
+func (r *dockerRuntime) Signal(ctx context.Context, id, signal string) error {
+	return r.client.ContainerKill(ctx, id, signal)
+}
+
+func (r *dockerRuntime) Exec(ctx context.Context, id, container string, command []string, output io.Writer) (int, error) {
+	config := types.ExecConfig{
+		Cmd:          command,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+
+	created, err := r.client.ContainerExecCreate(ctx, id, config)
+	if err != nil {
+		return 0, err
+	}
+
+	attached, err := r.client.ContainerExecAttach(ctx, created.ID, config)
+	if err != nil {
+		return 0, err
+	}
+	defer attached.Close()
+
+	// The hijacked connection does not follow ctx, close it to interrupt the reads
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attached.Close()
+		case <-done:
+		}
+	}()
+
+	if err := demuxLogs(output, attached.Reader); err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, err
+	}
+
+	// The stream can end slightly before the daemon records the exit code
+	for i := 0; ; i++ {
+		inspect, err := r.client.ContainerExecInspect(ctx, created.ID)
+		if err != nil {
+			return 0, err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		if i == 50 {
+			return 0, fmt.Errorf("command: %s in container: %s did not report its exit status", strings.Join(command, " "), id)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (r *dockerRuntime) Events(ctx context.Context, since time.Time) (<-chan ContainerEvent, <-chan error) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("type", "container")
+	// Adds the cron job
+	filterArgs.Add("event", "start")
+	filterArgs.Add("event", "create")
+
+	filterArgs.Add("event", "stop")
+	filterArgs.Add("event", "die")
+	// Podman's name of die
+	filterArgs.Add("event", "died")
+
+	// removes from the cron queue
+	filterArgs.Add("event", "destroy")
+	// Podman's name of destroy
+	filterArgs.Add("event", "remove")
+
+	options := types.EventsOptions{Filters: filterArgs}
+	if !since.IsZero() {
+		options.Since = since.UTC().Format(time.RFC3339Nano)
+	}
+	messages, errs := r.client.Events(ctx, options)
+
+	events := make(chan ContainerEvent)
+	go func() {
+		for {
+			select {
+			case msg := <-messages:
+				select {
+				case events <- r.containerEvent(ctx, msg):
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, errs
+}
+
+// Close leaves the client open, it is shared by the jobs of the endpoint
+func (r *dockerRuntime) Close() error {
+	return nil
+}
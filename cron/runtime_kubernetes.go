@@ -0,0 +1,240 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/container-crontab/kubernetes"
+)
+
+// kubernetesRuntime is the Runtime of the pods of a Kubernetes cluster. A pod
+// is scheduled through its annotations and identified as <pod>.<namespace>,
+// its containers are restarted by deleting it or rolling out its workload.
+type kubernetesRuntime struct {
+	client    kubernetes.Client
+	namespace string
+	selector  string
+
+	// resourceVersion is the version of the last pod change watched, where a
+	// reconnecting watch resumes
+	resourceVersion string
+	lock            sync.Mutex
+}
+
+func newKubernetesRuntime(endpoint *Endpoint) (Runtime, error) {
+	if endpoint.Kubernetes == nil {
+		return nil, fmt.Errorf("Kubernetes endpoint: %s has no client", endpoint)
+	}
+	return &kubernetesRuntime{
+		client:    endpoint.Kubernetes,
+		namespace: endpoint.Namespace,
+		selector:  endpoint.Selector,
+	}, nil
+}
+
+// podID returns the container ID of a pod
+func podID(pod *kubernetes.Pod) string {
+	return pod.Metadata.Name + "." + pod.Metadata.Namespace
+}
+
+// parsePodID returns the namespace and name of the pod of a container ID. Pod
+// names may contain dots, namespaces cannot.
+func parsePodID(id string) (string, string, error) {
+	i := strings.LastIndex(id, ".")
+	if i <= 0 || i == len(id)-1 {
+		return "", "", fmt.Errorf("Invalid pod id: %s, expected <pod>.<namespace>", id)
+	}
+	return id[i+1:], id[:i], nil
+}
+
+func podContainer(pod *kubernetes.Pod) Container {
+	return Container{
+		ID:      podID(pod),
+		Name:    podID(pod),
+		Labels:  pod.Metadata.Annotations,
+		Running: pod.Status.Phase == kubernetes.PodRunning && pod.Metadata.DeletionTimestamp == "",
+	}
+}
+
+func (r *kubernetesRuntime) Ping(ctx context.Context) error {
+	return r.client.Version(ctx)
+}
+
+func (r *kubernetesRuntime) List(ctx context.Context) ([]Container, error) {
+	pods, err := r.client.ListPods(ctx, r.namespace, r.selector)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []Container{}
+	for i := range pods {
+		containers = append(containers, podContainer(&pods[i]))
+	}
+	return containers, nil
+}
+
+func (r *kubernetesRuntime) Inspect(ctx context.Context, id string) (Container, error) {
+	pod, err := r.pod(ctx, id)
+	if err != nil {
+		return Container{}, err
+	}
+	return podContainer(pod), nil
+}
+
+func (r *kubernetesRuntime) pod(ctx context.Context, id string) (*kubernetes.Pod, error) {
+	namespace, name, err := parsePodID(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.GetPod(ctx, namespace, name)
+}
+
+func (r *kubernetesRuntime) Start(ctx context.Context, id string) error {
+	return fmt.Errorf("Unsupported action: start for pod: %s", id)
+}
+
+func (r *kubernetesRuntime) Stop(ctx context.Context, id string, timeout time.Duration) error {
+	return fmt.Errorf("Unsupported action: stop for pod: %s", id)
+}
+
+func (r *kubernetesRuntime) Restart(ctx context.Context, id string, timeout time.Duration) error {
+	return fmt.Errorf("Unsupported action: restart for pod: %s, use delete or rollout-restart", id)
+}
+
+func (r *kubernetesRuntime) Pause(ctx context.Context, id string) error {
+	return fmt.Errorf("Unsupported action: pause for pod: %s", id)
+}
+
+func (r *kubernetesRuntime) Unpause(ctx context.Context, id string) error {
+	return fmt.Errorf("Unsupported action: unpause for pod: %s", id)
+}
+
+func (r *kubernetesRuntime) Signal(ctx context.Context, id, signal string) error {
+	return fmt.Errorf("Unsupported action: signal for pod: %s", id)
+}
+
+func (r *kubernetesRuntime) Exec(ctx context.Context, id, container string, command []string, output io.Writer) (int, error) {
+	pod, err := r.pod(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if container == "" {
+		container = kubernetes.DefaultContainer(pod)
+	}
+	return r.client.Exec(ctx, pod.Metadata.Namespace, pod.Metadata.Name, container, command, output)
+}
+
+// podAction runs an action acting on the whole pod, e.g. kubernetes.DeletePod
+func (r *kubernetesRuntime) podAction(ctx context.Context, id string, action func(context.Context, kubernetes.Client, *kubernetes.Pod) error) error {
+	pod, err := r.pod(ctx, id)
+	if err != nil {
+		return err
+	}
+	return action(ctx, r.client, pod)
+}
+
+// Events resumes a reconnecting stream from the resource version of the last
+// pod change rather than from since, Kubernetes watches are not kept by time.
+// Once that version expired, the watch starts over by reporting every current
+// pod as added.
+func (r *kubernetesRuntime) Events(ctx context.Context, since time.Time) (<-chan ContainerEvent, <-chan error) {
+	resourceVersion := ""
+	if !since.IsZero() {
+		resourceVersion = r.lastVersion()
+	}
+	watched, watchErrs := r.client.WatchPods(ctx, r.namespace, r.selector, resourceVersion)
+
+	events := make(chan ContainerEvent)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case watch := <-watched:
+				r.setLastVersion(watch.Object.Metadata.ResourceVersion)
+				event, ok := podEvent(watch)
+				if !ok {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-watchErrs:
+				if apiErr, ok := err.(*kubernetes.APIError); ok && apiErr.Code == http.StatusGone {
+					r.setLastVersion("")
+				}
+				errs <- err
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, errs
+}
+
+func (r *kubernetesRuntime) lastVersion() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.resourceVersion
+}
+
+func (r *kubernetesRuntime) setLastVersion(version string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.resourceVersion = version
+}
+
+// podEvent converts a change of a pod into the action of its container: start
+// once running, die once completed, destroy once being deleted. Pending pods
+// have no action yet.
+func podEvent(watch kubernetes.WatchEvent) (ContainerEvent, bool) {
+	pod := &watch.Object
+	event := ContainerEvent{
+		ID:         podID(pod),
+		Attributes: map[string]string{},
+	}
+	for key, value := range pod.Metadata.Annotations {
+		event.Attributes[key] = value
+	}
+	event.Attributes["name"] = podID(pod)
+
+	switch {
+	case watch.Type == kubernetes.Deleted || pod.Metadata.DeletionTimestamp != "":
+		event.Action = "destroy"
+	case pod.Status.Phase == kubernetes.PodRunning:
+		event.Action = "start"
+	case pod.Status.Phase == kubernetes.PodSucceeded || pod.Status.Phase == kubernetes.PodFailed:
+		event.Action = "die"
+	default:
+		return ContainerEvent{}, false
+	}
+	return event, true
+}
+
+func (r *kubernetesRuntime) Close() error {
+	return nil
+}
+
+// podAction runs an action only Kubernetes pods support
+func (dj *DockerJob) podAction(action func(context.Context, kubernetes.Client, *kubernetes.Pod) error) {
+	var runtime Runtime
+	runtime, dj.lastError = dj.runtime()
+	if dj.Err() != nil {
+		return
+	}
+	defer runtime.Close()
+
+	pods, ok := runtime.(*kubernetesRuntime)
+	if !ok {
+		dj.lastError = fmt.Errorf("Unsupported action: %s for container id: %s, it only applies to Kubernetes pods", dj.Action, dj.ID)
+		return
+	}
+	dj.lastError = pods.podAction(context.Background(), dj.ID, action)
+}
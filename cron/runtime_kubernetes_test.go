@@ -0,0 +1,245 @@
+package cron
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rancher/container-crontab/kubernetes"
+)
+
+func testPod(name, owner string, annotations map[string]string) *kubernetes.Pod {
+	pod := &kubernetes.Pod{
+		Metadata: kubernetes.ObjectMeta{
+			Name:        name,
+			Namespace:   "shop",
+			Labels:      map[string]string{"app": "web"},
+			Annotations: annotations,
+		},
+		Spec: kubernetes.PodSpec{
+			Containers: []kubernetes.Container{{Name: "app"}, {Name: "sidecar"}},
+		},
+		Status: kubernetes.PodStatus{Phase: kubernetes.PodRunning},
+	}
+	if owner != "" {
+		pod.Metadata.OwnerReferences = []kubernetes.OwnerReference{{Kind: "ReplicaSet", Name: owner, Controller: true}}
+	}
+	return pod
+}
+
+func testKubernetesEndpoint(fake *kubernetes.Fake) *Endpoint {
+	return &Endpoint{Runtime: RuntimeKubernetes, Selector: "app=web", Kubernetes: fake}
+}
+
+// triggerPod adds the job of a pod and runs it, returning its error
+func triggerPod(t *testing.T, fake *kubernetes.Fake, pod *kubernetes.Pod) string {
+	crontab, err := NewCrontab()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer crontab.cronRunner.Stop()
+
+	endpoint := testKubernetesEndpoint(fake)
+	id := podID(pod)
+	if err := crontab.AddJob(endpoint, id, id, pod.Metadata.Annotations, "docker"); err != nil {
+		t.Fatalf("adding %s: %s", id, err)
+	}
+	jobEntry, err := crontab.GetJob(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobEntry.Job.Trigger()
+	history := jobEntry.Job.History()
+	if len(history) != 1 {
+		t.Fatalf("expected 1 run of %s, got %d", id, len(history))
+	}
+	return history[0].Error
+}
+
+func TestParsePodID(t *testing.T) {
+	namespace, name, err := parsePodID("web.v2-7d9f8.shop")
+	if err != nil || namespace != "shop" || name != "web.v2-7d9f8" {
+		t.Errorf("got %q %q %v", namespace, name, err)
+	}
+
+	for _, id := range []string{"web", ".shop", "web."} {
+		if _, _, err := parsePodID(id); err == nil {
+			t.Errorf("%s: expected an error", id)
+		}
+	}
+}
+
+func TestPodEvent(t *testing.T) {
+	annotations := map[string]string{"cron.schedule": "@hourly"}
+	deleting := testPod("web-1", "web-rs", annotations)
+	deleting.Metadata.DeletionTimestamp = "2026-10-19T03:00:00Z"
+	pending := testPod("web-1", "web-rs", annotations)
+	pending.Status.Phase = kubernetes.PodPending
+	failed := testPod("web-1", "web-rs", annotations)
+	failed.Status.Phase = kubernetes.PodFailed
+
+	for _, test := range []struct {
+		name   string
+		event  kubernetes.WatchEvent
+		action string
+	}{
+		{"running", kubernetes.WatchEvent{Type: kubernetes.Added, Object: *testPod("web-1", "web-rs", annotations)}, "start"},
+		{"modified", kubernetes.WatchEvent{Type: kubernetes.Modified, Object: *testPod("web-1", "web-rs", annotations)}, "start"},
+		{"pending", kubernetes.WatchEvent{Type: kubernetes.Added, Object: *pending}, ""},
+		{"failed", kubernetes.WatchEvent{Type: kubernetes.Modified, Object: *failed}, "die"},
+		{"deleting", kubernetes.WatchEvent{Type: kubernetes.Modified, Object: *deleting}, "destroy"},
+		{"deleted", kubernetes.WatchEvent{Type: kubernetes.Deleted, Object: *testPod("web-1", "web-rs", annotations)}, "destroy"},
+	} {
+		event, ok := podEvent(test.event)
+		if test.action == "" {
+			if ok {
+				t.Errorf("%s: expected no event, got %+v", test.name, event)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: expected a %s event", test.name, test.action)
+			continue
+		}
+		if event.ID != "web-1.shop" || event.Action != test.action {
+			t.Errorf("%s: expected %s of web-1.shop, got %s of %s", test.name, test.action, event.Action, event.ID)
+		}
+		expected := map[string]string{"cron.schedule": "@hourly", "name": "web-1.shop"}
+		if !reflect.DeepEqual(event.Attributes, expected) {
+			t.Errorf("%s: expected attributes %v, got %v", test.name, expected, event.Attributes)
+		}
+	}
+}
+
+func TestKubernetesList(t *testing.T) {
+	fake := kubernetes.NewFake(
+		testPod("web-1", "web-rs", map[string]string{"cron.schedule": "@hourly"}),
+		&kubernetes.Pod{Metadata: kubernetes.ObjectMeta{Name: "db-0", Namespace: "shop"}},
+	)
+	runtime, err := testKubernetesEndpoint(fake).Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers, err := runtime.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Container{{
+		ID:      "web-1.shop",
+		Name:    "web-1.shop",
+		Labels:  map[string]string{"cron.schedule": "@hourly"},
+		Running: true,
+	}}
+	if !reflect.DeepEqual(containers, expected) {
+		t.Errorf("expected %+v, got %+v", expected, containers)
+	}
+}
+
+func TestKubernetesExec(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		container string
+		code      int
+		expected  string
+		err       string
+	}{
+		{name: "default container", expected: "app"},
+		{name: "named container", container: "sidecar", expected: "sidecar"},
+		{name: "exit code", code: 3, expected: "app", err: "exited with status 3"},
+	} {
+		annotations := map[string]string{
+			"cron.schedule": "@hourly",
+			"cron.action":   "exec",
+			"cron.command":  `["backup", "--full"]`,
+		}
+		if test.container != "" {
+			annotations["cron.container"] = test.container
+		}
+		pod := testPod("web-1", "web-rs", annotations)
+		fake := kubernetes.NewFake(pod)
+		fake.ExecCode = test.code
+
+		err := triggerPod(t, fake, pod)
+		if (err == "") != (test.err == "") || !strings.Contains(err, test.err) {
+			t.Errorf("%s: expected error %q, got %q", test.name, test.err, err)
+		}
+
+		expected := []kubernetes.ExecCall{{Namespace: "shop", Pod: "web-1", Container: test.expected, Command: []string{"backup", "--full"}}}
+		if !reflect.DeepEqual(fake.Execs, expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, expected, fake.Execs)
+		}
+	}
+}
+
+func TestKubernetesDelete(t *testing.T) {
+	annotations := map[string]string{"cron.schedule": "@hourly", "cron.action": "delete"}
+	pod := testPod("web-1", "web-rs", annotations)
+	orphan := testPod("debug", "", annotations)
+	fake := kubernetes.NewFake(pod, orphan)
+
+	if err := triggerPod(t, fake, pod); err != "" {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if err := triggerPod(t, fake, orphan); !strings.Contains(err, "has no controller") {
+		t.Errorf("expected a pod without controller to be refused, got %q", err)
+	}
+	if expected := []string{"shop/web-1"}; !reflect.DeepEqual(fake.Deleted, expected) {
+		t.Errorf("expected %v deleted, got %v", expected, fake.Deleted)
+	}
+}
+
+func TestKubernetesRolloutRestart(t *testing.T) {
+	annotations := map[string]string{"cron.schedule": "@hourly", "cron.action": "rollout-restart"}
+	pod := testPod("web-1", "web-rs", annotations)
+	stateful := testPod("db-0", "", annotations)
+	stateful.Metadata.OwnerReferences = []kubernetes.OwnerReference{{Kind: "StatefulSet", Name: "db", Controller: true}}
+	unowned := testPod("batch-1", "batch-rs", annotations)
+
+	fake := kubernetes.NewFake(pod, stateful, unowned)
+	fake.AddReplicaSet(&kubernetes.ReplicaSet{Metadata: kubernetes.ObjectMeta{
+		Name:            "web-rs",
+		Namespace:       "shop",
+		OwnerReferences: []kubernetes.OwnerReference{{Kind: "Deployment", Name: "web", Controller: true}},
+	}})
+	fake.AddReplicaSet(&kubernetes.ReplicaSet{Metadata: kubernetes.ObjectMeta{Name: "batch-rs", Namespace: "shop"}})
+
+	for _, p := range []*kubernetes.Pod{pod, stateful} {
+		if err := triggerPod(t, fake, p); err != "" {
+			t.Errorf("%s: expected no error, got %s", p.Metadata.Name, err)
+		}
+	}
+	if err := triggerPod(t, fake, unowned); !strings.Contains(err, "not owned by a Deployment") {
+		t.Errorf("expected a bare ReplicaSet to be refused, got %q", err)
+	}
+
+	expected := []string{"Deployment/shop/web", "StatefulSet/shop/db"}
+	if !reflect.DeepEqual(fake.Restarted, expected) {
+		t.Errorf("expected %v restarted, got %v", expected, fake.Restarted)
+	}
+}
+
+func TestKubernetesActions(t *testing.T) {
+	crontab, err := NewCrontab()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer crontab.cronRunner.Stop()
+
+	endpoint := testKubernetesEndpoint(kubernetes.NewFake())
+	for _, action := range []string{"start", "stop", "restart", "pause", "signal", "http", "run"} {
+		labels := map[string]string{"cron.schedule": "@hourly", "cron.action": action, "cron.signal": "SIGHUP", "cron.http.port": "80"}
+		if err := crontab.AddJob(endpoint, "web-1.shop", "web-1.shop", labels, "docker"); err == nil {
+			t.Errorf("%s: expected the action to be refused on pods", action)
+		}
+	}
+
+	for _, action := range []string{"delete", "rollout-restart"} {
+		labels := map[string]string{"cron.schedule": "@hourly", "cron.action": action}
+		if err := crontab.AddJob(nil, "web", "web", labels, "docker"); err == nil {
+			t.Errorf("%s: expected the action to be refused on Docker containers", action)
+		}
+	}
+}
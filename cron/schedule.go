@@ -0,0 +1,118 @@
+package cron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/robfig/cron.v2"
+)
+
+// scheduleLabels are the labels that turn a container into a job, one of them is required
+var scheduleLabels = []string{"schedule", "at", "delay_after_finish", "after"}
+
+// HasSchedule reports whether a container's labels ask for a cron job
+func HasSchedule(labels map[string]string) bool {
+	return len(splitJobLabels(labels)) > 0
+}
+
+// splitJobLabels returns the labels of each job defined on a container, by job name.
+// The job configured by cron.* labels has an empty name. Named jobs are configured by
+// cron.<name>.* labels, e.g. cron.freeze.schedule and cron.freeze.action, and get them
+// without the name, as if they were the only job on the container.
+func splitJobLabels(labels map[string]string) map[string]map[string]string {
+	names := map[string]bool{}
+	for key := range labels {
+		for _, scheduleLabel := range scheduleLabels {
+			if key == "cron."+scheduleLabel {
+				names[""] = true
+			}
+			suffix := "." + scheduleLabel
+			if strings.HasPrefix(key, "cron.") && strings.HasSuffix(key, suffix) && len(key) > len("cron.")+len(suffix) {
+				names[key[len("cron."):len(key)-len(suffix)]] = true
+			}
+		}
+	}
+
+	jobs := map[string]map[string]string{}
+	for name := range names {
+		prefix := "cron."
+		if name != "" {
+			prefix = "cron." + name + "."
+		}
+
+		jobLabels := map[string]string{}
+		for key, value := range labels {
+			switch {
+			case name == "" && isNamedJobLabel(key, names):
+			case strings.HasPrefix(key, prefix):
+				jobLabels["cron."+strings.TrimPrefix(key, prefix)] = value
+			case !strings.HasPrefix(key, "cron."):
+				jobLabels[key] = value
+			}
+		}
+		jobs[name] = jobLabels
+	}
+
+	return jobs
+}
+
+func isNamedJobLabel(key string, names map[string]bool) bool {
+	for name := range names {
+		if name != "" && strings.HasPrefix(key, "cron."+name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseJobSchedule builds the schedule of a job from its labels and returns
+// it together with a readable form of it, resolving H fields from seed. Jobs
+// chained with cron.after have no schedule.
+func parseJobSchedule(id, seed string, labels map[string]string) (string, cron.Schedule, error) {
+	spec, hasSpec := labels["cron.schedule"]
+	at, hasAt := labels["cron.at"]
+	delay, hasDelay := labels["cron.delay_after_finish"]
+	after, hasAfter := labels["cron.after"]
+
+	modes := 0
+	for _, has := range []bool{hasSpec, hasAt, hasDelay, hasAfter} {
+		if has {
+			modes++
+		}
+	}
+
+	switch {
+	case modes > 1:
+		return "", nil, fmt.Errorf("cron.schedule, cron.at, cron.delay_after_finish and cron.after are mutually exclusive on container: %s", id)
+	case hasAt:
+		schedule, err := ParseAtSchedule(at)
+		return "at " + at, schedule, err
+	case hasDelay:
+		d, err := time.ParseDuration(delay)
+		if err != nil || d <= 0 {
+			return "", nil, fmt.Errorf("Invalid cron.delay_after_finish: %s on container: %s", delay, id)
+		}
+		return fmt.Sprintf("%s after finish", d), &AtSchedule{Times: []time.Time{time.Now().Add(d)}}, nil
+	case hasAfter:
+		condition, err := parseAfter(after)
+		if err != nil {
+			return "", nil, err
+		}
+		return condition.String(), nil, nil
+	case !hasSpec:
+		return "", nil, fmt.Errorf("No cron schedule found for container: %s", id)
+	}
+
+	expanded, err := ExpandHashedSpec(spec, seed)
+	if err != nil {
+		return "", nil, err
+	}
+	if expanded != spec {
+		logrus.Debugf("Expanded schedule: %s to %s for %s", spec, expanded, id)
+	}
+
+	schedule, err := cron.Parse(expanded)
+	return spec, schedule, err
+}
@@ -0,0 +1,79 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// signals accepted by cron.signal, as understood by the Docker daemon on Linux
+var signals = map[string]int{
+	"SIGHUP":    1,
+	"SIGINT":    2,
+	"SIGQUIT":   3,
+	"SIGILL":    4,
+	"SIGTRAP":   5,
+	"SIGABRT":   6,
+	"SIGBUS":    7,
+	"SIGFPE":    8,
+	"SIGKILL":   9,
+	"SIGUSR1":   10,
+	"SIGSEGV":   11,
+	"SIGUSR2":   12,
+	"SIGPIPE":   13,
+	"SIGALRM":   14,
+	"SIGTERM":   15,
+	"SIGSTKFLT": 16,
+	"SIGCHLD":   17,
+	"SIGCONT":   18,
+	"SIGSTOP":   19,
+	"SIGTSTP":   20,
+	"SIGTTIN":   21,
+	"SIGTTOU":   22,
+	"SIGURG":    23,
+	"SIGXCPU":   24,
+	"SIGXFSZ":   25,
+	"SIGVTALRM": 26,
+	"SIGPROF":   27,
+	"SIGWINCH":  28,
+	"SIGIO":     29,
+	"SIGPWR":    30,
+	"SIGSYS":    31,
+}
+
+// parseSignal normalizes a signal given as a name, with or without the SIG prefix, or a number
+func parseSignal(value string) (string, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return "", fmt.Errorf("cron.signal is required with the signal action")
+	}
+
+	if n, err := strconv.Atoi(value); err == nil {
+		for name, number := range signals {
+			if number == n {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("Unknown signal number: %d", n)
+	}
+
+	if !strings.HasPrefix(value, "SIG") {
+		value = "SIG" + value
+	}
+	if _, ok := signals[value]; !ok {
+		return "", fmt.Errorf("Unknown signal: %s", value)
+	}
+	return value, nil
+}
+
+func (dj *DockerJob) signal() {
+	var runtime Runtime
+	runtime, dj.lastError = dj.runtime()
+	if dj.Err() != nil {
+		return
+	}
+	defer runtime.Close()
+
+	dj.lastError = runtime.Signal(context.Background(), dj.ID, dj.signalName)
+}
@@ -0,0 +1,156 @@
+package cron
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// saveDelay batches the state changes made in quick succession into one write
+const saveDelay = time.Second
+
+// JobState is what the daemon remembers about a job across restarts
+type JobState struct {
+	LastTick       time.Time `json:"last_tick"`
+	SuccessfulRuns int       `json:"successful_runs,omitempty"`
+	Runs           int       `json:"runs,omitempty"`
+}
+
+// StateStore keeps JobState by job key in a JSON file
+type StateStore struct {
+	path   string
+	jobs   map[string]*JobState
+	saving *time.Timer
+	lock   sync.Mutex
+}
+
+// NewStateStore loads the state file at path. An empty path keeps the state in memory only.
+func NewStateStore(path string) (*StateStore, error) {
+	store := &StateStore{
+		path: path,
+		jobs: map[string]*JobState{},
+	}
+
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &store.jobs); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Get returns a copy of the state recorded for a job
+func (s *StateStore) Get(id string) JobState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if state, ok := s.jobs[id]; ok {
+		return *state
+	}
+	return JobState{}
+}
+
+// Update changes the state of a job, written to disk within saveDelay
+func (s *StateStore) Update(id string, update func(*JobState)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state, ok := s.jobs[id]
+	if !ok {
+		state = &JobState{}
+		s.jobs[id] = state
+	}
+	update(state)
+
+	s.save()
+}
+
+// Remove forgets a job
+func (s *StateStore) Remove(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.jobs[id]; ok {
+		delete(s.jobs, id)
+		s.save()
+	}
+}
+
+// RemoveContainer forgets all jobs of a container
+func (s *StateStore) RemoveContainer(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	removed := false
+	for key := range s.jobs {
+		if key == id || strings.HasPrefix(key, id+"/") {
+			delete(s.jobs, key)
+			removed = true
+		}
+	}
+
+	if removed {
+		s.save()
+	}
+}
+
+// save schedules a write of the state file. The caller must hold lock.
+func (s *StateStore) save() {
+	if s.path == "" || s.saving != nil {
+		return
+	}
+	s.saving = time.AfterFunc(saveDelay, s.Flush)
+}
+
+// Flush writes the pending state changes to disk
+func (s *StateStore) Flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.saving == nil {
+		return
+	}
+	s.saving.Stop()
+	s.saving = nil
+	s.write()
+}
+
+func (s *StateStore) write() {
+	data, err := json.Marshal(s.jobs)
+	if err != nil {
+		logrus.Errorf("Error encoding job state: %s", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		logrus.Errorf("Error creating state directory: %s", err)
+		return
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state file
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		logrus.Errorf("Error writing job state: %s", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		logrus.Errorf("Error writing job state: %s", err)
+	}
+}
@@ -2,13 +2,12 @@ package events
 
 import (
 	"context"
+	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/events"
-	"github.com/docker/docker/client"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rancher/container-crontab/cron"
+	"github.com/rancher/container-crontab/notify"
 )
 
 // Handler handles messages
@@ -17,16 +16,29 @@ type Handler interface {
 }
 
 // Message is a message from an event stream
-type Message *events.Message
+type Message *cron.ContainerEvent
 
 // DockerHandler handles docker messages
 type DockerHandler struct {
-	Crontab *cron.Crontab
+	Crontab   *cron.Crontab
+	Health    *Health
+	Endpoints []*cron.Endpoint
+	endpoint  *cron.Endpoint
 }
 
 type DockerHandlerOpts struct {
 	RancherMode bool
 	MetadataURL string
+	StateFile   string
+	Calendar    string
+	OutputDir   string
+	OutputSize  int
+	OutputKeep  int
+	Notifier    notify.Notifier
+	AuditFile   string
+	AuditSize   int64
+	AuditKeep   int
+	Endpoints   []*cron.Endpoint
 }
 
 // NewDockerHandler returns a docker handler with crontab
@@ -44,57 +56,114 @@ func NewDockerHandler(opts *DockerHandlerOpts) (*DockerHandler, error) {
 		}
 	}
 
-	dClient, err := client.NewEnvClient()
+	state, err := cron.NewStateStore(opts.StateFile)
 	if err != nil {
 		return nil, err
 	}
-	defer dClient.Close()
+	crontab.SetStateStore(state)
+	crontab.SetOutputStore(cron.NewOutputStore(opts.OutputDir, opts.OutputSize, opts.OutputKeep))
+	crontab.SetNotifier(opts.Notifier)
+	crontab.SetAuditLog(cron.NewAuditLog(opts.AuditFile, opts.AuditSize, opts.AuditKeep))
 
-	containers, err := dClient.ContainerList(context.Background(), types.ContainerListOptions{
-		All: true,
-	})
+	if opts.Calendar != "" {
+		calendar, err := cron.LoadCalendar(opts.Calendar)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("Loaded %d blackout windows from calendar: %s", len(calendar.Windows), opts.Calendar)
+		crontab.SetCalendar(calendar)
+	}
+
+	endpoints := opts.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []*cron.Endpoint{{}}
+	}
+
+	health := NewHealth(crontab, endpoints)
+	for _, endpoint := range endpoints {
+		if err := scan(crontab, endpoint); err != nil {
+			logrus.Errorf("Error scanning containers of Docker endpoint: %s: %s", endpoint, err)
+			go rescan(crontab, endpoint, health)
+			continue
+		}
+		health.ScanDone(endpoint)
+	}
+
+	return &DockerHandler{
+		Crontab:   crontab,
+		Health:    health,
+		Endpoints: endpoints,
+	}, nil
+}
+
+// ForEndpoint returns the handler of the events of endpoint
+func (dh DockerHandler) ForEndpoint(endpoint *cron.Endpoint) DockerHandler {
+	dh.endpoint = endpoint
+	return dh
+}
+
+// scan adds the jobs of the containers of endpoint
+func scan(crontab *cron.Crontab, endpoint *cron.Endpoint) error {
+	runtime, err := endpoint.Connect()
 	if err != nil {
-		logrus.Fatal(err)
-		return nil, err
+		return err
+	}
+	defer runtime.Close()
+
+	containers, err := runtime.List(context.Background())
+	if err != nil {
+		return err
 	}
 
 	// Scan containers
-	logrus.Infof("Scanning for container cron entries")
+	logrus.Infof("Scanning for container cron entries on Docker endpoint: %s", endpoint)
 	for _, container := range containers {
-		if _, ok := container.Labels["cron.schedule"]; ok {
-			crontab.AddJob(container.ID, container.Labels, "docker")
+		if cron.HasSchedule(container.Labels) {
+			crontab.AddJob(endpoint, container.ID, container.Name, container.Labels, "docker")
 		}
 	}
+	return nil
+}
 
-	return &DockerHandler{
-		Crontab: crontab,
-	}, nil
+// rescan retries the startup scan of an endpoint that was unreachable until it succeeds
+func rescan(crontab *cron.Crontab, endpoint *cron.Endpoint, health *Health) {
+	for {
+		time.Sleep(maxStreamBackoff)
+		if err := scan(crontab, endpoint); err != nil {
+			logrus.Errorf("Error scanning containers of Docker endpoint: %s: %s", endpoint, err)
+			continue
+		}
+		health.ScanDone(endpoint)
+		return
+	}
 }
 
 // Handle implements handler interface
 func (dh DockerHandler) Handle(msg Message) {
-	// Adding a cron.schedule label flags the container for deeper inspection
+	// Adding a cron.schedule or cron.at label flags the container for deeper inspection
 	// With this service
-	if _, ok := msg.Actor.Attributes["cron.schedule"]; ok {
+	if cron.HasSchedule(msg.Attributes) || dh.Crontab.HasJobs(dh.endpoint, msg.ID) {
 		if msg.Action == "start" || msg.Action == "create" {
 			logrus.Debugf("Processing %s event for container: %s", msg.Action, msg.ID)
-			dh.Crontab.AddJob(msg.ID, msg.Actor.Attributes, "docker")
+			dh.Crontab.AddJob(dh.endpoint, msg.ID, msg.Attributes["name"], msg.Attributes, "docker")
 		}
 
 		if msg.Action == "stop" || msg.Action == "die" {
 			logrus.Debugf("Proccessing %s event for container: %s", msg.Action, msg.ID)
-			dh.Crontab.DeactivateJob(msg.ID, msg.Actor.Attributes)
+			dh.Crontab.DeactivateJob(dh.endpoint, msg.ID, msg.Attributes)
 		}
 
 		if msg.Action == "destroy" {
 			logrus.Debugf("Processing destroy event for container: %s", msg.ID)
-			dh.Crontab.RemoveJob(msg.ID)
+			dh.Crontab.RemoveJob(dh.endpoint, msg.ID)
 		}
 	}
 }
 
 func (dh DockerHandler) GetJobStats(guage *prometheus.GaugeVec) (*prometheus.GaugeVec, error) {
-	guage.With(prometheus.Labels{"state": "active"}).Set(dh.Crontab.GetNumberOfActiveJobs())
-	guage.With(prometheus.Labels{"state": "inactive"}).Set(dh.Crontab.GetNumberOfInactiveJobs())
+	for _, endpoint := range dh.Endpoints {
+		guage.With(prometheus.Labels{"state": "active", "endpoint": endpoint.String()}).Set(dh.Crontab.GetNumberOfActiveJobs(endpoint))
+		guage.With(prometheus.Labels{"state": "inactive", "endpoint": endpoint.String()}).Set(dh.Crontab.GetNumberOfInactiveJobs(endpoint))
+	}
 	return guage, nil
 }
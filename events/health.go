@@ -0,0 +1,200 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rancher/container-crontab/cron"
+)
+
+const (
+	// healthTimeout bounds each check so a hung dependency fails it instead of blocking the probe
+	healthTimeout = 5 * time.Second
+	// streamSettle is how long a new event stream has to stay up before it counts as connected
+	streamSettle = 5 * time.Second
+)
+
+// Check is the result of a single health check
+type Check struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthReport is the outcome of all checks of a probe
+type HealthReport struct {
+	OK     bool    `json:"ok"`
+	Checks []Check `json:"checks"`
+}
+
+// Health tracks the state of the startup scan and of the event stream of every
+// Docker endpoint, and checks the daemon's dependencies
+type Health struct {
+	Crontab   *cron.Crontab
+	Endpoints []*cron.Endpoint
+
+	scanned map[*cron.Endpoint]bool
+	streams map[*cron.Endpoint]*StreamHealth
+	lock    sync.Mutex
+}
+
+// StreamHealth tracks the state of the event stream of an endpoint
+type StreamHealth struct {
+	opened    time.Time
+	failed    time.Time
+	err       error
+	lastEvent time.Time
+	lock      sync.Mutex
+}
+
+// NewHealth returns the health of a daemon running crontab for the containers of endpoints
+func NewHealth(crontab *cron.Crontab, endpoints []*cron.Endpoint) *Health {
+	h := &Health{
+		Crontab:   crontab,
+		Endpoints: endpoints,
+		scanned:   map[*cron.Endpoint]bool{},
+		streams:   map[*cron.Endpoint]*StreamHealth{},
+	}
+	for _, endpoint := range endpoints {
+		h.streams[endpoint] = &StreamHealth{}
+	}
+	return h
+}
+
+// ScanDone records that the containers present on endpoint at startup were scanned
+func (h *Health) ScanDone(endpoint *cron.Endpoint) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.scanned[endpoint] = true
+}
+
+// Stream returns the tracker of the event stream of endpoint
+func (h *Health) Stream(endpoint *cron.Endpoint) *StreamHealth {
+	return h.streams[endpoint]
+}
+
+func (s *StreamHealth) streamOpen() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.opened = time.Now()
+}
+
+func (s *StreamHealth) streamError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failed = time.Now()
+	s.err = err
+}
+
+func (s *StreamHealth) eventReceived() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastEvent = time.Now()
+}
+
+// Live checks the process is responsive: the crontab can be locked in time
+func (h *Health) Live() HealthReport {
+	return report(timed("crontab", func() error {
+		h.Crontab.ListJobs()
+		return nil
+	}))
+}
+
+// Ready checks the daemon is able to schedule jobs: every Docker endpoint is
+// reachable, was scanned at startup and delivers events and, in Rancher mode,
+// metadata is reachable
+func (h *Health) Ready() HealthReport {
+	checks := []Check{}
+	for _, endpoint := range h.Endpoints {
+		suffix := ""
+		if len(h.Endpoints) > 1 {
+			suffix = " " + endpoint.String()
+		}
+
+		endpoint := endpoint
+		checks = append(checks,
+			timed("docker"+suffix, func() error { return pingDocker(endpoint) }),
+			h.scanCheck("scan"+suffix, endpoint),
+			h.streams[endpoint].check("events"+suffix),
+		)
+	}
+	if h.Crontab.RancherMode() {
+		checks = append(checks, timed("rancher-metadata", h.Crontab.CheckRancherMetadata))
+	}
+	return report(checks...)
+}
+
+func (h *Health) scanCheck(name string, endpoint *cron.Endpoint) Check {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if !h.scanned[endpoint] {
+		return Check{Name: name, Message: "startup container scan has not finished"}
+	}
+	return Check{Name: name, OK: true}
+}
+
+// check passes once the current event stream stayed up for streamSettle or delivered
+// an event. A silent stream is not a stalled one: the router periodically resumes it
+// on a new connection, which fails the check if the runtime stopped answering.
+func (s *StreamHealth) check(name string) Check {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	check := Check{Name: name}
+	switch {
+	case s.opened.IsZero():
+		check.Message = "event stream not opened yet"
+	case !s.failed.Before(s.opened):
+		check.Message = fmt.Sprintf("event stream failed at %s: %s", s.failed.Format(time.RFC3339), s.err)
+	case s.lastEvent.After(s.opened):
+		check.OK = true
+		check.Message = "last event at " + s.lastEvent.Format(time.RFC3339)
+	case time.Since(s.opened) >= streamSettle:
+		check.OK = true
+		check.Message = "connected since " + s.opened.Format(time.RFC3339)
+	default:
+		check.Message = "event stream reconnecting"
+	}
+	return check
+}
+
+// timed runs a check, failing it if it takes longer than healthTimeout
+func timed(name string, check func() error) Check {
+	result := make(chan error, 1)
+	go func() { result <- check() }()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return Check{Name: name, Message: err.Error()}
+		}
+		return Check{Name: name, OK: true}
+	case <-time.After(healthTimeout):
+		return Check{Name: name, Message: fmt.Sprintf("timed out after %s", healthTimeout)}
+	}
+}
+
+func pingDocker(endpoint *cron.Endpoint) error {
+	runtime, err := endpoint.Connect()
+	if err != nil {
+		return err
+	}
+	defer runtime.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+	return runtime.Ping(ctx)
+}
+
+func report(checks ...Check) HealthReport {
+	r := HealthReport{OK: true, Checks: checks}
+	for _, check := range checks {
+		if !check.OK {
+			r.OK = false
+		}
+	}
+	return r
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/container-crontab/cron"
+	"github.com/rancher/container-crontab/kubernetes"
+)
+
+func scheduledPod(name string) *kubernetes.Pod {
+	return &kubernetes.Pod{
+		Metadata: kubernetes.ObjectMeta{
+			Name:        name,
+			Namespace:   "shop",
+			Labels:      map[string]string{"app": "web"},
+			Annotations: map[string]string{"cron.schedule": "@hourly", "cron.action": "delete"},
+		},
+		Status: kubernetes.PodStatus{Phase: kubernetes.PodRunning},
+	}
+}
+
+// eventually polls condition until it holds or a second passed
+func eventually(t *testing.T, message string, condition func() bool) {
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(message)
+}
+
+func TestKubernetesPodsAreScheduled(t *testing.T) {
+	other := scheduledPod("worker-1")
+	other.Metadata.Labels["app"] = "worker"
+	fake := kubernetes.NewFake(scheduledPod("web-1"), other)
+	endpoint := &cron.Endpoint{Runtime: cron.RuntimeKubernetes, Selector: "app=web", Kubernetes: fake}
+
+	handler, err := NewDockerHandler(&DockerHandlerOpts{Endpoints: []*cron.Endpoint{endpoint}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !handler.Crontab.HasJobs(endpoint, "web-1.shop") {
+		t.Error("pod present at startup was not scanned")
+	}
+	if handler.Crontab.HasJobs(endpoint, "worker-1.shop") {
+		t.Error("pod not matching the selector was scheduled")
+	}
+
+	router, err := NewEventRouter(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go StartRouter(router, handler.ForEndpoint(endpoint), handler.Health.Stream(endpoint))
+
+	pending := scheduledPod("web-2")
+	pending.Status.Phase = kubernetes.PodPending
+	fake.SetPod(pending)
+	time.Sleep(50 * time.Millisecond)
+	if handler.Crontab.HasJobs(endpoint, "web-2.shop") {
+		t.Error("pending pod was scheduled")
+	}
+
+	fake.SetPod(scheduledPod("web-2"))
+	eventually(t, "running pod was not scheduled", func() bool {
+		return handler.Crontab.HasJobs(endpoint, "web-2.shop")
+	})
+
+	fake.RemovePod("shop", "web-1")
+	eventually(t, "deleted pod was not unscheduled", func() bool {
+		return !handler.Crontab.HasJobs(endpoint, "web-1.shop")
+	})
+
+	eventually(t, "event stream not ready", func() bool {
+		return handler.Health.Ready().OK
+	})
+}
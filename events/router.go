@@ -2,72 +2,109 @@ package events
 
 import (
 	"context"
+	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/events"
-	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/client"
+	"github.com/rancher/container-crontab/cron"
 )
 
 // Router Interface
 type Router interface {
-	Listen(context.Context) (<-chan events.Message, <-chan error)
+	Listen(ctx context.Context, since time.Time) (<-chan cron.ContainerEvent, <-chan error)
 }
 
-//DockerEventRouter is the Docker event handler implementation
-type DockerEventRouter struct {
-	DockerClient *client.Client
-	Handler      Handler
+// RuntimeEventRouter routes the container events of a runtime
+type RuntimeEventRouter struct {
+	Runtime cron.Runtime
+	Handler Handler
 }
 
-// NewEventRouter returns the a Docker event handler
-func NewEventRouter() (Router, error) {
-	dClient, err := client.NewEnvClient()
+// NewEventRouter returns the event router of the runtime of endpoint
+func NewEventRouter(endpoint *cron.Endpoint) (Router, error) {
+	runtime, err := endpoint.Connect()
 	if err != nil {
 		return nil, err
 	}
-	return DockerEventRouter{
-		DockerClient: dClient,
+	return RuntimeEventRouter{
+		Runtime: runtime,
 	}, nil
 }
 
-// StartRouter calls the listener function and takes the interface for testing
-func StartRouter(router Router, handler Handler) {
+// StartRouter calls the listener function and takes the interface for testing.
+// health, if set, is kept informed about the state of the event stream. A
+// reopened stream resumes after the last event received, or from when the
+// first stream opened, so no event is lost while reconnecting.
+func StartRouter(router Router, handler Handler, health *StreamHealth) {
+	backoff := time.Duration(0)
+	var since time.Time
 
 loop:
 	for {
+		time.Sleep(backoff)
+
+		opened := time.Now()
 		ctx, cancelFunc := context.WithCancel(context.Background())
-		eventStream, errChan := router.Listen(ctx)
+		eventStream, errChan := router.Listen(ctx, since)
+		if since.IsZero() {
+			since = opened
+		}
+		if health != nil {
+			health.streamOpen()
+		}
+		idle := time.NewTimer(streamIdle)
 		for {
 			select {
 			case event := <-eventStream:
+				backoff = 0
+				if !event.Time.IsZero() {
+					since = event.Time.Add(time.Nanosecond)
+				}
+				if health != nil {
+					health.eventReceived()
+				}
 				handler.Handle(&event)
+				if !idle.Stop() {
+					<-idle.C
+				}
+				idle.Reset(streamIdle)
+			case <-idle.C:
+				// A half-open connection delivers no event and no error either
+				logrus.Debugf("No event for %s, resuming the event stream from %s", streamIdle, since.Format(time.RFC3339Nano))
+				cancelFunc()
+				continue loop
 			case err := <-errChan:
+				idle.Stop()
 				logrus.Error(err)
+				if health != nil {
+					health.streamError(err)
+				}
 				cancelFunc()
+				backoff = nextBackoff(backoff)
 				continue loop
 			}
 		}
 	}
 }
 
-// Listen implements the Router interface
-func (de DockerEventRouter) Listen(ctx context.Context) (<-chan events.Message, <-chan error) {
-	filterArgs := filters.NewArgs()
-	// Adds the cron job
-	filterArgs.Add("event", "start")
-	filterArgs.Add("event", "create")
-
-	filterArgs.Add("event", "stop")
-	filterArgs.Add("event", "die")
-
-	// removes from the cron queue
-	filterArgs.Add("event", "destroy")
+const (
+	// maxStreamBackoff bounds the wait before reconnecting to a failing event stream
+	maxStreamBackoff = 30 * time.Second
+	// streamIdle is how long an event stream may stay silent before it is resumed on a new connection
+	streamIdle = 10 * time.Minute
+)
 
-	eventOptions := types.EventsOptions{
-		Filters: filterArgs,
+// nextBackoff doubles the wait before reconnecting to the event stream
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		return time.Second
 	}
+	if backoff *= 2; backoff > maxStreamBackoff {
+		return maxStreamBackoff
+	}
+	return backoff
+}
 
-	return de.DockerClient.Events(ctx, eventOptions)
+// Listen implements the Router interface
+func (re RuntimeEventRouter) Listen(ctx context.Context, since time.Time) (<-chan cron.ContainerEvent, <-chan error) {
+	return re.Runtime.Events(ctx, since)
 }
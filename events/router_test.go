@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rancher/container-crontab/cron"
+)
+
+// failingRouter delivers one event per stream, then fails it
+type failingRouter struct {
+	eventTime time.Time
+	since     chan time.Time
+}
+
+func (r failingRouter) Listen(ctx context.Context, since time.Time) (<-chan cron.ContainerEvent, <-chan error) {
+	r.since <- since
+
+	events := make(chan cron.ContainerEvent)
+	errs := make(chan error, 1)
+	go func() {
+		select {
+		case events <- cron.ContainerEvent{ID: "web", Action: "start", Time: r.eventTime}:
+		case <-ctx.Done():
+			return
+		}
+		errs <- errors.New("connection reset")
+	}()
+	return events, errs
+}
+
+type nopHandler struct{}
+
+func (nopHandler) Handle(Message) {}
+
+func TestStartRouterResumesAfterLastEvent(t *testing.T) {
+	eventTime := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	router := failingRouter{eventTime: eventTime, since: make(chan time.Time, 2)}
+	go StartRouter(router, nopHandler{}, nil)
+
+	for i, expected := range []time.Time{{}, eventTime.Add(time.Nanosecond)} {
+		select {
+		case since := <-router.since:
+			if !since.Equal(expected) {
+				t.Errorf("stream %d: expected to resume from %s, got %s", i, expected, since)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("stream %d was not opened", i)
+		}
+	}
+}
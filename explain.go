@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/container-crontab/cron"
+	"github.com/urfave/cli"
+)
+
+func explain(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return cli.NewExitError("a cron expression is required, e.g. explain \"0 30 2 * * *\"", 1)
+	}
+
+	seed := cron.HashSeed(c.String("endpoint"), c.String("name"), c.String("job"))
+	spec, err := cron.ExpandHashedSpec(strings.Join(c.Args(), " "), seed)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("invalid cron expression: %s", err), 1)
+	}
+
+	schedule, err := cron.ParseSchedule(spec, c.String("timezone"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("invalid cron expression: %s", err), 1)
+	}
+
+	loc := cron.ScheduleLocation(schedule)
+
+	fmt.Println(cron.Explain(schedule))
+	fmt.Println()
+	for _, run := range cron.NextRuns(schedule, time.Now(), c.Int("count")) {
+		fmt.Println(run.In(loc).Format(time.RFC1123Z))
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeletePod deletes a pod for its controller to replace it, which restarts all
+// of its containers. Pods without a controller are refused as they would be gone.
+func DeletePod(ctx context.Context, client Client, pod *Pod) error {
+	if pod.Metadata.Controller() == nil {
+		return fmt.Errorf("pod: %s/%s has no controller to recreate it", pod.Metadata.Namespace, pod.Metadata.Name)
+	}
+	return client.DeletePod(ctx, pod.Metadata.Namespace, pod.Metadata.Name)
+}
+
+// RolloutRestart restarts the workload owning a pod: the Deployment owning its
+// ReplicaSet, or its StatefulSet or DaemonSet
+func RolloutRestart(ctx context.Context, client Client, pod *Pod) error {
+	kind, name, err := Workload(ctx, client, pod)
+	if err != nil {
+		return err
+	}
+	return client.RestartWorkload(ctx, pod.Metadata.Namespace, kind, name)
+}
+
+// Workload returns the kind and name of the workload owning a pod
+func Workload(ctx context.Context, client Client, pod *Pod) (string, string, error) {
+	owner := pod.Metadata.Controller()
+	if owner == nil {
+		return "", "", fmt.Errorf("pod: %s/%s has no controller", pod.Metadata.Namespace, pod.Metadata.Name)
+	}
+
+	switch owner.Kind {
+	case "StatefulSet", "DaemonSet":
+		return owner.Kind, owner.Name, nil
+	case "ReplicaSet":
+		rs, err := client.GetReplicaSet(ctx, pod.Metadata.Namespace, owner.Name)
+		if err != nil {
+			return "", "", err
+		}
+		deployment := rs.Metadata.Controller()
+		if deployment == nil || deployment.Kind != "Deployment" {
+			return "", "", fmt.Errorf("ReplicaSet: %s/%s of pod: %s is not owned by a Deployment", pod.Metadata.Namespace, owner.Name, pod.Metadata.Name)
+		}
+		return deployment.Kind, deployment.Name, nil
+	}
+	return "", "", fmt.Errorf("pod: %s/%s is controlled by a %s, which cannot be restarted", pod.Metadata.Namespace, pod.Metadata.Name, owner.Kind)
+}
+
+// DefaultContainer returns the container commands are run in when none is
+// named: the one of the kubectl.kubernetes.io/default-container annotation, or the first one
+func DefaultContainer(pod *Pod) string {
+	if name := pod.Metadata.Annotations["kubectl.kubernetes.io/default-container"]; name != "" {
+		return name
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
+}
@@ -0,0 +1,300 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// requestTimeout bounds every request but watches and execs
+	requestTimeout = 30 * time.Second
+)
+
+// Client is the part of the Kubernetes API container-crontab uses. An empty
+// namespace means all namespaces and an empty selector all pods.
+type Client interface {
+	// Version checks the API server is reachable
+	Version(ctx context.Context) error
+	ListPods(ctx context.Context, namespace, selector string) ([]Pod, error)
+	// WatchPods streams the changes of the pods until ctx is done or the watch
+	// ends, starting after resourceVersion or, when it is empty, with an Added
+	// event for every existing pod
+	WatchPods(ctx context.Context, namespace, selector, resourceVersion string) (<-chan WatchEvent, <-chan error)
+	GetPod(ctx context.Context, namespace, name string) (*Pod, error)
+	DeletePod(ctx context.Context, namespace, name string) error
+	GetReplicaSet(ctx context.Context, namespace, name string) (*ReplicaSet, error)
+	// RestartWorkload rolls out the pods of a Deployment, StatefulSet or DaemonSet
+	// again, as kubectl rollout restart does
+	RestartWorkload(ctx context.Context, namespace, kind, name string) error
+	// Exec runs a command in a container of a pod, writing its stdout and stderr
+	// to output, and returns its exit code
+	Exec(ctx context.Context, namespace, pod, container string, command []string, output io.Writer) (int, error)
+}
+
+// RESTClient is the Client of an API server
+type RESTClient struct {
+	Server    string
+	tokenFile string
+	tls       *tls.Config
+	// proxy picks the proxy of requests and execs, from HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	proxy  func(*http.Request) (*url.URL, error)
+	client *http.Client
+}
+
+// NewInClusterClient returns the client of the API server of the cluster the
+// process runs in, authenticated with the pod's service account
+func NewInClusterClient() (*RESTClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	return NewClient("https://"+net.JoinHostPort(host, port), serviceAccountDir+"/token", serviceAccountDir+"/ca.crt")
+}
+
+// NewClient returns the client of the API server at server, authenticated with
+// the bearer token in tokenFile, if set, and trusting the CA in caFile, if set
+func NewClient(server, tokenFile, caFile string) (*RESTClient, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("expected an http or https URL for the Kubernetes API server, got: %s", server)
+	}
+
+	tlsConfig := &tls.Config{}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in: %s", caFile)
+		}
+	}
+
+	c := &RESTClient{
+		Server:    strings.TrimSuffix(server, "/"),
+		tokenFile: tokenFile,
+		tls:       tlsConfig,
+		proxy:     http.ProxyFromEnvironment,
+	}
+	c.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           func(req *http.Request) (*url.URL, error) { return c.proxy(req) },
+			TLSClientConfig: tlsConfig,
+		},
+	}
+	return c, nil
+}
+
+// Version implements Client
+func (c *RESTClient) Version(ctx context.Context) error {
+	return c.do(ctx, "GET", "/version", nil, "", nil)
+}
+
+// ListPods implements Client
+func (c *RESTClient) ListPods(ctx context.Context, namespace, selector string) ([]Pod, error) {
+	list := PodList{}
+	if err := c.do(ctx, "GET", podsPath(namespace, selector, false), nil, "", &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// WatchPods implements Client
+func (c *RESTClient) WatchPods(ctx context.Context, namespace, selector, resourceVersion string) (<-chan WatchEvent, <-chan error) {
+	events := make(chan WatchEvent)
+	errs := make(chan error, 1)
+
+	path := podsPath(namespace, selector, true)
+	if resourceVersion != "" {
+		path += "&resourceVersion=" + url.QueryEscape(resourceVersion)
+	}
+
+	go func() {
+		resp, err := c.send(ctx, "GET", path, nil, "")
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			raw := struct {
+				Type   string          `json:"type"`
+				Object json.RawMessage `json:"object"`
+			}{}
+			if err := decoder.Decode(&raw); err != nil {
+				if err == io.EOF {
+					err = fmt.Errorf("pod watch closed by the API server")
+				}
+				errs <- err
+				return
+			}
+
+			// The object of an error event is a Status, e.g. a 410 Gone when the
+			// resource version to resume from expired
+			if raw.Type == Error {
+				status := Status{}
+				json.Unmarshal(raw.Object, &status)
+				errs <- &APIError{Code: status.Code, Message: "pod watch failed: " + status.Message}
+				return
+			}
+			event := WatchEvent{Type: raw.Type}
+			if err := json.Unmarshal(raw.Object, &event.Object); err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, errs
+}
+
+// GetPod implements Client
+func (c *RESTClient) GetPod(ctx context.Context, namespace, name string) (*Pod, error) {
+	pod := &Pod{}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", namespace, name)
+	if err := c.do(ctx, "GET", path, nil, "", pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// DeletePod implements Client
+func (c *RESTClient) DeletePod(ctx context.Context, namespace, name string) error {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", namespace, name)
+	return c.do(ctx, "DELETE", path, nil, "", nil)
+}
+
+// GetReplicaSet implements Client
+func (c *RESTClient) GetReplicaSet(ctx context.Context, namespace, name string) (*ReplicaSet, error) {
+	rs := &ReplicaSet{}
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/replicasets/%s", namespace, name)
+	if err := c.do(ctx, "GET", path, nil, "", rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// RestartWorkload implements Client
+func (c *RESTClient) RestartWorkload(ctx context.Context, namespace, kind, name string) error {
+	resource := map[string]string{
+		"Deployment":  "deployments",
+		"StatefulSet": "statefulsets",
+		"DaemonSet":   "daemonsets",
+	}[kind]
+	if resource == "" {
+		return fmt.Errorf("cannot restart a %s", kind)
+	}
+
+	// Changing the pod template makes the controller replace every pod
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`, time.Now().Format(time.RFC3339))
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/%s/%s", namespace, resource, name)
+	return c.do(ctx, "PATCH", path, strings.NewReader(patch), "application/strategic-merge-patch+json", nil)
+}
+
+// do sends a request and decodes the response into out, when set
+func (c *RESTClient) do(ctx context.Context, method, path string, body io.Reader, contentType string, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := c.send(ctx, method, path, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request, turning error statuses into an APIError
+func (c *RESTClient) send(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.Server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if err := c.authorize(req.Header); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// authorize adds the bearer token, read on every request as projected
+// service account tokens are rotated
+func (c *RESTClient) authorize(header http.Header) error {
+	if c.tokenFile == "" {
+		return nil
+	}
+	token, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		return err
+	}
+	header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	status := Status{}
+	if err := json.Unmarshal(data, &status); err == nil && status.Message != "" {
+		return &APIError{Code: resp.StatusCode, Message: status.Message}
+	}
+	return &APIError{Code: resp.StatusCode, Message: strings.TrimSpace(resp.Status + " " + string(bytes.TrimSpace(data)))}
+}
+
+func podsPath(namespace, selector string, watch bool) string {
+	path := "/api/v1/pods"
+	if namespace != "" {
+		path = fmt.Sprintf("/api/v1/namespaces/%s/pods", namespace)
+	}
+
+	query := url.Values{}
+	if selector != "" {
+		query.Set("labelSelector", selector)
+	}
+	if watch {
+		query.Set("watch", "true")
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path
+}
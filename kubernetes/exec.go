@@ -0,0 +1,375 @@
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// execProtocol multiplexes stdout, stderr and the exit status over websocket messages
+	execProtocol  = "v4.channel.k8s.io"
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	stdoutChannel = 1
+	stderrChannel = 2
+	errorChannel  = 3
+
+	opContinuation = 0x0
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Exec implements Client over a websocket, the streaming protocol of the API
+// server that needs nothing beyond the standard library
+func (c *RESTClient) Exec(ctx context.Context, namespace, pod, container string, command []string, output io.Writer) (int, error) {
+	query := url.Values{}
+	query.Set("container", container)
+	query.Set("stdout", "true")
+	query.Set("stderr", "true")
+	for _, arg := range command {
+		query.Add("command", arg)
+	}
+	u, err := url.Parse(fmt.Sprintf("%s/api/v1/namespaces/%s/pods/%s/exec?%s", c.Server, namespace, pod, query.Encode()))
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := c.dial(ctx, u)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// Cancelling ctx interrupts the reads
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	reader, err := c.handshake(conn, u)
+	if err != nil {
+		return 0, err
+	}
+
+	ws := &websocket{conn: conn, reader: reader}
+	defer ws.close()
+
+	for {
+		message, err := ws.readMessage()
+		if err == io.EOF {
+			return 0, fmt.Errorf("exec in pod: %s ended without an exit status", pod)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, err
+		}
+		if len(message) < 2 {
+			continue
+		}
+
+		switch message[0] {
+		case stdoutChannel, stderrChannel:
+			output.Write(message[1:])
+		case errorChannel:
+			return exitCode(message[1:])
+		}
+	}
+}
+
+// exitCode reads the exit code of an exec from the status sent on the error channel
+func exitCode(data []byte) (int, error) {
+	status := Status{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return 0, fmt.Errorf("Error decoding exec status: %s", err)
+	}
+	if status.Status == "Success" {
+		return 0, nil
+	}
+	if status.Reason == "NonZeroExitCode" && status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			if cause.Reason == "ExitCode" {
+				return strconv.Atoi(cause.Message)
+			}
+		}
+	}
+	return 0, fmt.Errorf("exec failed: %s", status.Message)
+}
+
+// dial connects to the API server, through a CONNECT tunnel when the
+// environment sets a proxy for it as it does for the other requests
+func (c *RESTClient) dial(ctx context.Context, u *url.URL) (net.Conn, error) {
+	host := hostPort(u)
+	proxy, err := c.proxy(&http.Request{URL: u})
+	if err != nil {
+		return nil, err
+	}
+
+	address := host
+	if proxy != nil {
+		if proxy.Scheme != "http" && proxy.Scheme != "https" {
+			return nil, fmt.Errorf("unsupported proxy scheme: %s, expected http or https", proxy.Scheme)
+		}
+		address = hostPort(proxy)
+	}
+
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	// Bounds the proxy's answer and the TLS handshakes, the exec itself has no deadline
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if proxy != nil {
+		if proxy.Scheme == "https" {
+			conn, err = tlsHandshake(conn, &tls.Config{ServerName: proxy.Hostname()})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := connectTunnel(conn, proxy, host); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if u.Scheme == "https" {
+		config := c.tls.Clone()
+		config.ServerName = u.Hostname()
+		conn, err = tlsHandshake(conn, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// hostPort returns the host and port of u, the default one of its scheme if it has none
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+func tlsHandshake(conn net.Conn, config *tls.Config) (net.Conn, error) {
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// connectTunnel asks the proxy on conn to tunnel it to host
+func connectTunnel(conn net.Conn, proxy *url.URL, host string) error {
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: host},
+		Host:   host,
+		Header: http.Header{},
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	// Nothing follows the answer until the tunnel is used, the reader buffers no more
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy: %s refused to tunnel to %s: %s", proxy.Host, host, resp.Status)
+	}
+	return nil
+}
+
+// handshake upgrades the connection to a websocket speaking execProtocol
+func (c *RESTClient) handshake(conn net.Conn, u *url.URL) (*bufio.Reader, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Protocol", execProtocol)
+	if err := c.authorize(req.Header); err != nil {
+		return nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		return nil, fmt.Errorf("invalid websocket handshake from the API server")
+	}
+	if resp.Header.Get("Sec-WebSocket-Protocol") != execProtocol {
+		return nil, fmt.Errorf("the API server does not speak %s", execProtocol)
+	}
+	return reader, nil
+}
+
+// websocket is the client side of a websocket connection, enough to read messages
+type websocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// readMessage returns the next data message, reassembled from its frames
+func (ws *websocket) readMessage() ([]byte, error) {
+	message := []byte{}
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opClose:
+			return nil, io.EOF
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		}
+
+		if opcode != opContinuation {
+			message = message[:0]
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (ws *websocket) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(ws.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(ws.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(ws.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if length > 1<<24 {
+		return false, 0, nil, fmt.Errorf("websocket frame of %d bytes is too large", length)
+	}
+
+	mask := make([]byte, 4)
+	if masked {
+		if _, err := io.ReadFull(ws.reader, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame sends a single frame, masked as clients must
+func (ws *websocket) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	default:
+		return fmt.Errorf("websocket frame of %d bytes is too large", len(payload))
+	}
+
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// close tells the server the client is done, ignoring errors of a connection already closed
+func (ws *websocket) close() {
+	ws.writeFrame(opClose, []byte{0x03, 0xe8})
+}
@@ -0,0 +1,290 @@
+package kubernetes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	successStatus = `{"status":"Success"}`
+	exitStatus    = `{"status":"Failure","reason":"NonZeroExitCode","message":"command terminated with non-zero exit code","details":{"causes":[{"reason":"ExitCode","message":"3"}]}}`
+	failedStatus  = `{"status":"Failure","message":"container sidecar not found"}`
+)
+
+// serverFrame encodes a frame sent by the server, which are not masked
+func serverFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	if len(payload) < 126 {
+		frame = append(frame, byte(len(payload)))
+	} else {
+		frame = append(frame, 126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	return append(frame, payload...)
+}
+
+func channel(id byte, data string) []byte {
+	return append([]byte{id}, data...)
+}
+
+// readClientFrame reads a frame sent by the client, failing unless it is masked
+func readClientFrame(t *testing.T, reader *bufio.Reader) (byte, []byte) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		t.Errorf("reading client frame: %s", err)
+		return 0, nil
+	}
+	if header[1]&0x80 == 0 {
+		t.Error("client frame is not masked")
+	}
+
+	mask := make([]byte, 4)
+	payload := make([]byte, header[1]&0x7f)
+	io.ReadFull(reader, mask)
+	io.ReadFull(reader, payload)
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return header[0] & 0x0f, payload
+}
+
+// execServer answers exec requests by upgrading them to a websocket and
+// running session on it
+func execServer(t *testing.T, session func(r *http.Request, conn net.Conn, reader *bufio.Reader)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Protocol") != execProtocol {
+			http.Error(w, "not a websocket request", http.StatusBadRequest)
+			return
+		}
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		accept := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+		fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\nSec-WebSocket-Protocol: %s\r\n\r\n",
+			base64.StdEncoding.EncodeToString(accept[:]), execProtocol)
+		session(r, conn, rw.Reader)
+	}))
+}
+
+func testClient(t *testing.T, server string) *RESTClient {
+	token, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token.WriteString("secret\n")
+	token.Close()
+
+	client, err := NewClient(server, token.Name(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestExec(t *testing.T) {
+	large := strings.Repeat("x", 300)
+	requests := make(chan *http.Request, 1)
+	server := execServer(t, func(r *http.Request, conn net.Conn, reader *bufio.Reader) {
+		requests <- r
+
+		// stdout fragmented over two frames, with a ping in between
+		conn.Write(serverFrame(false, 0x2, channel(stdoutChannel, "hel")))
+		conn.Write(serverFrame(true, opPing, []byte("are you there")))
+		if opcode, payload := readClientFrame(t, reader); opcode != opPong || string(payload) != "are you there" {
+			t.Errorf("expected a pong echoing the ping, got opcode %x: %q", opcode, payload)
+		}
+		conn.Write(serverFrame(true, opContinuation, []byte("lo\n")))
+		conn.Write(serverFrame(true, 0x2, channel(stderrChannel, "oops\n")))
+		conn.Write(serverFrame(true, 0x2, channel(stdoutChannel, large)))
+		conn.Write(serverFrame(true, 0x2, channel(errorChannel, exitStatus)))
+
+		if opcode, _ := readClientFrame(t, reader); opcode != opClose {
+			t.Errorf("expected a close frame, got opcode %x", opcode)
+		}
+	})
+	defer server.Close()
+
+	client := testClient(t, server.URL)
+	defer os.Remove(client.tokenFile)
+
+	var output bytes.Buffer
+	code, err := client.Exec(context.Background(), "shop", "web-1", "sidecar", []string{"backup", "--full"}, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Errorf("expected exit code 3, got %d", code)
+	}
+	if expected := "hello\noops\n" + large; output.String() != expected {
+		t.Errorf("expected output %q, got %q", expected, output.String())
+	}
+
+	r := <-requests
+	if r.URL.Path != "/api/v1/namespaces/shop/pods/web-1/exec" {
+		t.Errorf("wrong exec path: %s", r.URL.Path)
+	}
+	query := r.URL.Query()
+	if query.Get("container") != "sidecar" || query.Get("stdout") != "true" || query.Get("stderr") != "true" {
+		t.Errorf("wrong exec query: %s", r.URL.RawQuery)
+	}
+	if !reflect.DeepEqual(query["command"], []string{"backup", "--full"}) {
+		t.Errorf("wrong exec command: %v", query["command"])
+	}
+	if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+		t.Errorf("wrong authorization: %q", auth)
+	}
+}
+
+func TestExecStatus(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status string
+		code   int
+		err    string
+	}{
+		{name: "success", status: successStatus},
+		{name: "exit code", status: exitStatus, code: 3},
+		{name: "failure", status: failedStatus, err: "container sidecar not found"},
+		{name: "no status", err: "ended without an exit status"},
+	} {
+		server := execServer(t, func(r *http.Request, conn net.Conn, reader *bufio.Reader) {
+			if test.status != "" {
+				conn.Write(serverFrame(true, 0x2, channel(errorChannel, test.status)))
+			} else {
+				conn.Write(serverFrame(true, opClose, []byte{0x03, 0xe8}))
+			}
+			readClientFrame(t, reader)
+		})
+
+		client := testClient(t, server.URL)
+		code, err := client.Exec(context.Background(), "shop", "web-1", "app", []string{"true"}, ioutil.Discard)
+		if test.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+		if code != test.code {
+			t.Errorf("%s: expected exit code %d, got %d", test.name, test.code, code)
+		}
+
+		os.Remove(client.tokenFile)
+		server.Close()
+	}
+}
+
+// connectProxy tunnels CONNECT requests, sending the tunneled hosts to hosts
+func connectProxy(t *testing.T, hosts chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			http.Error(w, "only CONNECT is proxied", http.StatusMethodNotAllowed)
+			return
+		}
+		hosts <- r.Host
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	}))
+}
+
+func TestExecThroughProxy(t *testing.T) {
+	server := execServer(t, func(r *http.Request, conn net.Conn, reader *bufio.Reader) {
+		conn.Write(serverFrame(true, 0x2, channel(stdoutChannel, "proxied\n")))
+		conn.Write(serverFrame(true, 0x2, channel(errorChannel, successStatus)))
+		readClientFrame(t, reader)
+	})
+	defer server.Close()
+
+	hosts := make(chan string, 1)
+	proxy := connectProxy(t, hosts)
+	defer proxy.Close()
+
+	client := testClient(t, server.URL)
+	defer os.Remove(client.tokenFile)
+	proxyURL, _ := url.Parse(proxy.URL)
+	client.proxy = http.ProxyURL(proxyURL)
+
+	var output bytes.Buffer
+	if _, err := client.Exec(context.Background(), "shop", "web-1", "app", []string{"true"}, &output); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "proxied\n" {
+		t.Errorf("expected the output through the proxy, got %q", output.String())
+	}
+	if host, expected := <-hosts, strings.TrimPrefix(server.URL, "http://"); host != expected {
+		t.Errorf("expected a tunnel to %s, got %s", expected, host)
+	}
+}
+
+func TestExecForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"kind":"Status","status":"Failure","message":"pods \"web-1\" is forbidden","code":403}`)
+	}))
+	defer server.Close()
+
+	client := testClient(t, server.URL)
+	defer os.Remove(client.tokenFile)
+
+	_, err := client.Exec(context.Background(), "shop", "web-1", "app", []string{"true"}, ioutil.Discard)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Code != http.StatusForbidden || !strings.Contains(apiErr.Message, "forbidden") {
+		t.Errorf("expected a 403 APIError, got %v", err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, test := range []struct {
+		status string
+		code   int
+		err    bool
+	}{
+		{status: successStatus},
+		{status: exitStatus, code: 3},
+		{status: failedStatus, err: true},
+		{status: `{"status":"Failure","reason":"NonZeroExitCode","details":{"causes":[{"reason":"ExitCode","message":"x"}]}}`, err: true},
+		{status: `not json`, err: true},
+	} {
+		code, err := exitCode([]byte(test.status))
+		if (err != nil) != test.err || code != test.code {
+			t.Errorf("%s: expected %d, error %t, got %d, %v", test.status, test.code, test.err, code, err)
+		}
+	}
+}
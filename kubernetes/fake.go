@@ -0,0 +1,241 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ExecCall is a command run in a pod of a Fake
+type ExecCall struct {
+	Namespace string
+	Pod       string
+	Container string
+	Command   []string
+}
+
+// Fake is an in-memory Client to test against. Pods added, updated and deleted
+// through it are sent to its watchers; execs write ExecOutput and exit with ExecCode.
+// Label selectors are matched as comma separated key=value pairs.
+type Fake struct {
+	Pods        map[string]*Pod
+	ReplicaSets map[string]*ReplicaSet
+	ExecOutput  string
+	ExecCode    int
+
+	Execs     []ExecCall
+	Deleted   []string
+	Restarted []string
+
+	watchers []chan WatchEvent
+	lock     sync.Mutex
+}
+
+// NewFake returns a Fake holding pods
+func NewFake(pods ...*Pod) *Fake {
+	f := &Fake{
+		Pods:        map[string]*Pod{},
+		ReplicaSets: map[string]*ReplicaSet{},
+	}
+	for _, pod := range pods {
+		f.Pods[objectKey(pod.Metadata)] = pod
+	}
+	return f
+}
+
+// AddReplicaSet adds a ReplicaSet, to look up the Deployment owning pods
+func (f *Fake) AddReplicaSet(rs *ReplicaSet) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.ReplicaSets[objectKey(rs.Metadata)] = rs
+}
+
+// SetPod adds or updates a pod and tells the watchers
+func (f *Fake) SetPod(pod *Pod) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	eventType := Added
+	if _, ok := f.Pods[objectKey(pod.Metadata)]; ok {
+		eventType = Modified
+	}
+	f.Pods[objectKey(pod.Metadata)] = pod
+	f.notify(WatchEvent{Type: eventType, Object: *pod})
+}
+
+// RemovePod deletes a pod and tells the watchers
+func (f *Fake) RemovePod(namespace, name string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.removePod(namespace, name)
+}
+
+func (f *Fake) removePod(namespace, name string) {
+	key := namespace + "/" + name
+	if pod, ok := f.Pods[key]; ok {
+		delete(f.Pods, key)
+		f.notify(WatchEvent{Type: Deleted, Object: *pod})
+	}
+}
+
+func (f *Fake) notify(event WatchEvent) {
+	for _, watcher := range f.watchers {
+		watcher <- event
+	}
+}
+
+// Version implements Client
+func (f *Fake) Version(ctx context.Context) error {
+	return nil
+}
+
+// ListPods implements Client
+func (f *Fake) ListPods(ctx context.Context, namespace, selector string) ([]Pod, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	pods := []Pod{}
+	for _, pod := range f.Pods {
+		if matches(pod, namespace, selector) {
+			pods = append(pods, *pod)
+		}
+	}
+	return pods, nil
+}
+
+// WatchPods implements Client. Events are buffered, a watcher not reading them
+// blocks the Fake once 100 are pending. The Fake keeps no past changes, a watch
+// resuming from a resourceVersion only gets the following ones.
+func (f *Fake) WatchPods(ctx context.Context, namespace, selector, resourceVersion string) (<-chan WatchEvent, <-chan error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	all := make(chan WatchEvent, 100)
+	if resourceVersion == "" {
+		for _, pod := range f.Pods {
+			all <- WatchEvent{Type: Added, Object: *pod}
+		}
+	}
+	f.watchers = append(f.watchers, all)
+
+	events := make(chan WatchEvent)
+	go func() {
+		for {
+			select {
+			case event := <-all:
+				if !matches(&event.Object, namespace, selector) {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					f.removeWatcher(all)
+					return
+				}
+			case <-ctx.Done():
+				f.removeWatcher(all)
+				return
+			}
+		}
+	}()
+	return events, make(chan error)
+}
+
+func (f *Fake) removeWatcher(watcher chan WatchEvent) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i, w := range f.watchers {
+		if w == watcher {
+			f.watchers = append(f.watchers[:i], f.watchers[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetPod implements Client
+func (f *Fake) GetPod(ctx context.Context, namespace, name string) (*Pod, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	pod, ok := f.Pods[namespace+"/"+name]
+	if !ok {
+		return nil, notFound("pods", name)
+	}
+	found := *pod
+	return &found, nil
+}
+
+// DeletePod implements Client
+func (f *Fake) DeletePod(ctx context.Context, namespace, name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.Pods[namespace+"/"+name]; !ok {
+		return notFound("pods", name)
+	}
+	f.Deleted = append(f.Deleted, namespace+"/"+name)
+	f.removePod(namespace, name)
+	return nil
+}
+
+// GetReplicaSet implements Client
+func (f *Fake) GetReplicaSet(ctx context.Context, namespace, name string) (*ReplicaSet, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	rs, ok := f.ReplicaSets[namespace+"/"+name]
+	if !ok {
+		return nil, notFound("replicasets.apps", name)
+	}
+	return rs, nil
+}
+
+// RestartWorkload implements Client
+func (f *Fake) RestartWorkload(ctx context.Context, namespace, kind, name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.Restarted = append(f.Restarted, fmt.Sprintf("%s/%s/%s", kind, namespace, name))
+	return nil
+}
+
+// Exec implements Client
+func (f *Fake) Exec(ctx context.Context, namespace, pod, container string, command []string, output io.Writer) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.Pods[namespace+"/"+pod]; !ok {
+		return 0, notFound("pods", pod)
+	}
+	f.Execs = append(f.Execs, ExecCall{Namespace: namespace, Pod: pod, Container: container, Command: command})
+	io.WriteString(output, f.ExecOutput)
+	return f.ExecCode, nil
+}
+
+func objectKey(meta ObjectMeta) string {
+	return meta.Namespace + "/" + meta.Name
+}
+
+// matches tells whether a pod is in namespace, unless empty, and has the labels of selector
+func matches(pod *Pod, namespace, selector string) bool {
+	if namespace != "" && pod.Metadata.Namespace != namespace {
+		return false
+	}
+	for _, requirement := range strings.Split(selector, ",") {
+		if requirement == "" {
+			continue
+		}
+		parts := strings.SplitN(requirement, "=", 2)
+		if len(parts) != 2 || pod.Metadata.Labels[parts[0]] != parts[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func notFound(resource, name string) error {
+	return &APIError{Code: http.StatusNotFound, Message: fmt.Sprintf("%s %q not found", resource, name)}
+}
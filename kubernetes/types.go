@@ -0,0 +1,127 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Watch event types
+const (
+	Added    = "ADDED"
+	Modified = "MODIFIED"
+	Deleted  = "DELETED"
+	Error    = "ERROR"
+)
+
+// Pod phases
+const (
+	PodPending   = "Pending"
+	PodRunning   = "Running"
+	PodSucceeded = "Succeeded"
+	PodFailed    = "Failed"
+)
+
+// ObjectMeta is the metadata of an API object
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	DeletionTimestamp string            `json:"deletionTimestamp,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	OwnerReferences   []OwnerReference  `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference points to the object owning another
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+	Controller bool   `json:"controller,omitempty"`
+}
+
+// Controller returns the owner managing the object, nil if there is none
+func (m ObjectMeta) Controller() *OwnerReference {
+	for i := range m.OwnerReferences {
+		if m.OwnerReferences[i].Controller {
+			return &m.OwnerReferences[i]
+		}
+	}
+	return nil
+}
+
+// Pod is the part of a pod container-crontab reads
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     PodSpec    `json:"spec"`
+	Status   PodStatus  `json:"status"`
+}
+
+// PodSpec lists the containers of a pod
+type PodSpec struct {
+	Containers []Container `json:"containers"`
+}
+
+// Container is a container of a pod
+type Container struct {
+	Name string `json:"name"`
+}
+
+// PodStatus is the phase of a pod
+type PodStatus struct {
+	Phase string `json:"phase,omitempty"`
+}
+
+// PodList is a page of pods
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// ReplicaSet is the part of a ReplicaSet needed to find the Deployment owning it
+type ReplicaSet struct {
+	Metadata ObjectMeta `json:"metadata"`
+}
+
+// WatchEvent is a change of a pod
+type WatchEvent struct {
+	Type   string `json:"type"`
+	Object Pod    `json:"object"`
+}
+
+// Status is the body of API errors
+type Status struct {
+	Status  string         `json:"status"`
+	Message string         `json:"message"`
+	Reason  string         `json:"reason"`
+	Code    int            `json:"code"`
+	Details *StatusDetails `json:"details,omitempty"`
+}
+
+// StatusDetails carries the causes of a failure, the exit code of an exec
+type StatusDetails struct {
+	Causes []StatusCause `json:"causes,omitempty"`
+}
+
+// StatusCause is a cause of a failure
+type StatusCause struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// APIError is an error answered by the API server
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("kubernetes API error %d: %s", e.Code, e.Message)
+}
+
+// IsNotFound tells whether err is the API server not finding an object
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.Code == http.StatusNotFound
+}
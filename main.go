@@ -1,10 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/rancher/container-crontab/control"
+	"github.com/rancher/container-crontab/cron"
 	"github.com/rancher/container-crontab/events"
+	"github.com/rancher/container-crontab/kubernetes"
+	"github.com/rancher/container-crontab/notify"
 	"github.com/urfave/cli"
 )
 
@@ -41,33 +49,417 @@ func main() {
 			Value: MetadataURL,
 			Usage: "Provide full URL of Metadata",
 		},
+		cli.StringSliceFlag{
+			Name:  "docker-endpoint",
+			Usage: "Docker daemon to schedule containers of, as name=..,host=..[,cert-path=..][,tls-verify=..][,api-version=..]; repeat for several daemons",
+		},
+		cli.BoolFlag{
+			Name:  "kubernetes",
+			Usage: "Schedule the pods of a Kubernetes cluster through their annotations instead of Docker containers",
+		},
+		cli.StringFlag{
+			Name:  "kube-namespace",
+			Usage: "Namespace of the scheduled pods, all namespaces by default",
+		},
+		cli.StringFlag{
+			Name:  "kube-selector",
+			Usage: "Label selector of the scheduled pods",
+		},
+		cli.StringFlag{
+			Name:  "kube-api-server",
+			Usage: "URL of the Kubernetes API server, the in-cluster one by default",
+		},
+		cli.StringFlag{
+			Name:  "kube-token-file",
+			Usage: "Bearer token file authenticating to --kube-api-server",
+		},
+		cli.StringFlag{
+			Name:  "kube-ca-file",
+			Usage: "CA certificate verifying --kube-api-server",
+		},
 		cli.BoolFlag{
 			Name: "metrics",
 		},
+		cli.StringFlag{
+			Name:  "metrics-listen",
+			Value: ":9191",
+			Usage: "Address of the metrics listener, or unix:///path/to/socket",
+		},
+		cli.StringFlag{
+			Name:  "metrics-path",
+			Value: "/metrics",
+		},
+		cli.StringFlag{
+			Name:  "metrics-tls-cert",
+			Usage: "Serve metrics over HTTPS with this certificate",
+		},
+		cli.StringFlag{
+			Name:  "metrics-tls-key",
+			Usage: "Key of --metrics-tls-cert",
+		},
+		cli.StringFlag{
+			Name:  "metrics-client-ca",
+			Usage: "Require client certificates signed by this CA",
+		},
+		cli.StringFlag{
+			Name:   "metrics-token",
+			EnvVar: "METRICS_TOKEN",
+			Usage:  "Require an Authorization: Bearer header with this token",
+		},
+		cli.StringFlag{
+			Name:  "metrics-token-file",
+			Usage: "Read the token of --metrics-token from a file",
+		},
+		cli.StringFlag{
+			Name:  "socket",
+			Value: control.DefaultSocket,
+			Usage: "Unix socket for the control API, set to empty to disable",
+		},
+		cli.StringFlag{
+			Name:  "state-dir",
+			Value: "/var/lib/container-crontab",
+			Usage: "Directory where job state is kept across restarts, set to empty to keep it in memory",
+		},
+		cli.StringFlag{
+			Name:  "output-dir",
+			Usage: "Directory where the output of runs is kept across restarts, kept in memory when empty",
+		},
+		cli.IntFlag{
+			Name:  "output-size",
+			Value: cron.DefaultOutputSize,
+			Usage: "Number of bytes kept from the end of the output of each run",
+		},
+		cli.IntFlag{
+			Name:  "output-keep",
+			Value: cron.DefaultOutputKeep,
+			Usage: "Number of runs of each job whose output is kept",
+		},
+		cli.StringSliceFlag{
+			Name:  "notify-webhook",
+			Usage: "URL to POST the outcome of runs to as JSON, can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name:  "notify-slack",
+			Usage: "Slack or Mattermost incoming webhook URL to post the outcome of runs to, can be repeated",
+		},
+		cli.StringFlag{
+			Name:  "smtp-host",
+			Usage: "SMTP server to send the emails requested with cron.notify.email through",
+		},
+		cli.IntFlag{
+			Name:  "smtp-port",
+			Value: 587,
+		},
+		cli.BoolFlag{
+			Name:  "smtp-tls",
+			Usage: "Connect to the SMTP server over implicit TLS, the default on port 465",
+		},
+		cli.BoolTFlag{
+			Name:  "smtp-starttls",
+			Usage: "Upgrade the SMTP connection with STARTTLS, disable for servers without TLS support",
+		},
+		cli.StringFlag{
+			Name: "smtp-username",
+		},
+		cli.StringFlag{
+			Name:   "smtp-password",
+			EnvVar: "SMTP_PASSWORD",
+		},
+		cli.StringFlag{
+			Name:  "smtp-from",
+			Value: "container-crontab@localhost",
+		},
+		cli.DurationFlag{
+			Name:  "smtp-digest",
+			Usage: "Send each address at most one email per interval, e.g. 1h, listing all runs of the interval",
+		},
+		cli.Int64Flag{
+			Name:  "audit-size",
+			Value: cron.DefaultAuditSize,
+			Usage: "Size in bytes at which the audit log in the state directory is rotated",
+		},
+		cli.IntFlag{
+			Name:  "audit-keep",
+			Value: cron.DefaultAuditKeep,
+			Usage: "Number of rotated audit logs to keep",
+		},
+		cli.StringFlag{
+			Name:  "calendar",
+			Usage: "iCalendar (.ics) or YAML file of holidays and freeze periods during which no job runs",
+		},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:      "explain",
+			Aliases:   []string{"next"},
+			Usage:     "Describe a cron expression and list its next fire times",
+			ArgsUsage: "<cron expression>",
+			Action:    explain,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "count,n",
+					Value: 5,
+					Usage: "Number of upcoming fire times to print",
+				},
+				cli.StringFlag{
+					Name:  "timezone,tz",
+					Usage: "Time zone to evaluate the expression in, e.g. Europe/Berlin",
+				},
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "Container name used to resolve H fields",
+				},
+				cli.StringFlag{
+					Name:  "job",
+					Usage: "Name of the job, for schedules set with cron.<job>.schedule",
+				},
+				cli.StringFlag{
+					Name:  "endpoint",
+					Usage: "Name of the container's --docker-endpoint, if named",
+				},
+			},
+		},
+		{
+			Name:    "ps",
+			Aliases: []string{"list"},
+			Usage:   "List the jobs of the running daemon",
+			Action:  listJobs,
+		},
+		{
+			Name:      "inspect",
+			Usage:     "Show the details of a job",
+			ArgsUsage: "<job>",
+			Action:    inspectJob,
+		},
+		{
+			Name:      "history",
+			Usage:     "Show the recent runs of a job",
+			ArgsUsage: "<job>",
+			Action:    jobHistory,
+		},
+		{
+			Name:      "logs",
+			Usage:     "Print the output of the latest run of a job, or of the given run",
+			ArgsUsage: "<job>",
+			Action:    jobLogs,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "run",
+					Usage: "Run number, as listed by history",
+				},
+			},
+		},
+		{
+			Name:      "audit",
+			Usage:     "Show why the jobs, or the given job, ran or were skipped recently",
+			ArgsUsage: "[job]",
+			Action:    auditLog,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "limit,n",
+					Value: 50,
+					Usage: "Number of decisions to show",
+				},
+			},
+		},
+		{
+			Name:   "healthcheck",
+			Usage:  "Exit with status 0 when the running daemon is ready, for use as a HEALTHCHECK",
+			Action: healthcheck,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "live",
+					Usage: "Only check the daemon is responsive, not that it can schedule jobs",
+				},
+				cli.StringFlag{
+					Name:  "url",
+					Usage: "Check the daemon's HTTP listener at this URL instead of the control socket, e.g. http://127.0.0.1:9191",
+				},
+				cli.StringFlag{
+					Name:   "token",
+					EnvVar: "METRICS_TOKEN",
+					Usage:  "Bearer token of the HTTP listener",
+				},
+			},
+		},
+		{
+			Name:      "trigger",
+			Usage:     "Run a job now, outside of its schedule",
+			ArgsUsage: "<job>",
+			Action:    triggerJob,
+		},
+		{
+			Name:      "pause",
+			Usage:     "Pause a job, or every job when none is given",
+			ArgsUsage: "[job]",
+			Action:    pauseJob,
+		},
+		{
+			Name:      "resume",
+			Usage:     "Resume a job, or every job when none is given",
+			ArgsUsage: "[job]",
+			Action:    resumeJob,
+		},
 	}
 
 	app.Run(os.Args)
 }
 
 func start(c *cli.Context) error {
+	endpoints, err := dockerEndpoints(c)
+	if c.GlobalBool("kubernetes") {
+		endpoints, err = kubernetesEndpoints(c)
+	}
+	if err != nil {
+		return err
+	}
+
 	handler, err := events.NewDockerHandler(&events.DockerHandlerOpts{
+		Endpoints:   endpoints,
 		RancherMode: c.GlobalBool("rancher-mode"),
 		MetadataURL: c.GlobalString("metadata-url"),
+		StateFile:   stateFile(c),
+		Calendar:    c.GlobalString("calendar"),
+		OutputDir:   c.GlobalString("output-dir"),
+		OutputSize:  c.GlobalInt("output-size"),
+		OutputKeep:  c.GlobalInt("output-keep"),
+		Notifier:    notifier(c),
+		AuditFile:   auditFile(c),
+		AuditSize:   c.GlobalInt64("audit-size"),
+		AuditKeep:   c.GlobalInt("audit-keep"),
 	})
 	if err != nil {
 		return err
 	}
 
-	router, err := events.NewEventRouter()
-	if err != nil {
-		return err
+	routers := map[*cron.Endpoint]events.Router{}
+	for _, endpoint := range handler.Endpoints {
+		router, err := events.NewEventRouter(endpoint)
+		if err != nil {
+			return err
+		}
+		routers[endpoint] = router
 	}
 
 	if c.GlobalBool("metrics") {
-		go MetricsServer(handler)
+		token, err := metricsToken(c.GlobalString("metrics-token"), c.GlobalString("metrics-token-file"))
+		if err != nil {
+			return err
+		}
+		go MetricsServer(handler, MetricsOpts{
+			Listen:   c.GlobalString("metrics-listen"),
+			Path:     c.GlobalString("metrics-path"),
+			TLSCert:  c.GlobalString("metrics-tls-cert"),
+			TLSKey:   c.GlobalString("metrics-tls-key"),
+			ClientCA: c.GlobalString("metrics-client-ca"),
+			Token:    token,
+		})
 	}
 
-	events.StartRouter(router, handler)
+	if socket := c.GlobalString("socket"); socket != "" {
+		go func() {
+			logrus.Error(control.NewServer(socket, handler.Crontab, handler.Health).ListenAndServe())
+		}()
+	}
+
+	for endpoint, router := range routers {
+		go events.StartRouter(router, handler.ForEndpoint(endpoint), handler.Health.Stream(endpoint))
+	}
 
+	// Job state is written in batches, flush it before exiting
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	logrus.Infof("Received %s, exiting", sig)
+	handler.Crontab.FlushState()
 	return nil
 }
+
+// dockerEndpoints returns the Docker daemons given with --docker-endpoint, none
+// meaning the one of the DOCKER_* environment variables
+func dockerEndpoints(c *cli.Context) ([]*cron.Endpoint, error) {
+	values := c.GlobalStringSlice("docker-endpoint")
+	endpoints := []*cron.Endpoint{}
+	names := map[string]bool{}
+	for _, value := range values {
+		endpoint, err := cron.ParseEndpoint(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) > 1 && endpoint.Name == "" {
+			return nil, fmt.Errorf("Docker endpoint: %s needs a name when several are given", value)
+		}
+		if names[endpoint.Name] {
+			return nil, fmt.Errorf("Duplicate Docker endpoint name: %s", endpoint.Name)
+		}
+		names[endpoint.Name] = true
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// kubernetesEndpoints returns the cluster given with --kubernetes, reached from
+// inside it unless --kube-api-server is set
+func kubernetesEndpoints(c *cli.Context) ([]*cron.Endpoint, error) {
+	if len(c.GlobalStringSlice("docker-endpoint")) > 0 {
+		return nil, fmt.Errorf("--docker-endpoint cannot be combined with --kubernetes")
+	}
+
+	var client kubernetes.Client
+	var err error
+	if server := c.GlobalString("kube-api-server"); server != "" {
+		client, err = kubernetes.NewClient(server, c.GlobalString("kube-token-file"), c.GlobalString("kube-ca-file"))
+	} else {
+		client, err = kubernetes.NewInClusterClient()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return []*cron.Endpoint{{
+		Runtime:    cron.RuntimeKubernetes,
+		Namespace:  c.GlobalString("kube-namespace"),
+		Selector:   c.GlobalString("kube-selector"),
+		Kubernetes: client,
+	}}, nil
+}
+
+func stateFile(c *cli.Context) string {
+	if dir := c.GlobalString("state-dir"); dir != "" {
+		return filepath.Join(dir, "state.json")
+	}
+	return ""
+}
+
+func auditFile(c *cli.Context) string {
+	if dir := c.GlobalString("state-dir"); dir != "" {
+		return filepath.Join(dir, "audit.log")
+	}
+	return ""
+}
+
+func notifier(c *cli.Context) notify.Notifier {
+	notifiers := notify.Multi{}
+	for _, url := range c.GlobalStringSlice("notify-webhook") {
+		notifiers = append(notifiers, notify.NewWebhook(url, notify.FormatJSON))
+	}
+	for _, url := range c.GlobalStringSlice("notify-slack") {
+		notifiers = append(notifiers, notify.NewWebhook(url, notify.FormatSlack))
+	}
+	if host := c.GlobalString("smtp-host"); host != "" {
+		notifiers = append(notifiers, notify.NewEmail(notify.SMTPConfig{
+			Host:     host,
+			Port:     c.GlobalInt("smtp-port"),
+			TLS:      c.GlobalBool("smtp-tls") || c.GlobalInt("smtp-port") == 465,
+			StartTLS: c.GlobalBoolT("smtp-starttls"),
+			Username: c.GlobalString("smtp-username"),
+			Password: c.GlobalString("smtp-password"),
+			From:     c.GlobalString("smtp-from"),
+		}, c.GlobalDuration("smtp-digest")))
+	}
+
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notifiers
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rancher/container-crontab/control"
 	"github.com/rancher/container-crontab/events"
 )
 
@@ -15,6 +24,17 @@ var (
 	activeJobGauge *prometheus.GaugeVec
 )
 
+// MetricsOpts configures the HTTP listener serving metrics and health probes
+type MetricsOpts struct {
+	// Listen is a TCP address, or unix:///path/to/socket
+	Listen   string
+	Path     string
+	TLSCert  string
+	TLSKey   string
+	ClientCA string
+	Token    string
+}
+
 func initMetrics() {
 	hostname, _ := os.Hostname()
 	activeJobGauge = prometheus.NewGaugeVec(
@@ -22,7 +42,7 @@ func initMetrics() {
 			Name:        "rancher_container_crontab_jobs_total",
 			Help:        "Number of container crontab job entries",
 			ConstLabels: prometheus.Labels{"hostname": hostname},
-		}, []string{"state"})
+		}, []string{"state", "endpoint"})
 	prometheus.MustRegister(activeJobGauge)
 }
 
@@ -33,11 +53,106 @@ func collectMetrics(handler *events.DockerHandler) {
 	}
 }
 
-func MetricsServer(handler *events.DockerHandler) {
+func MetricsServer(handler *events.DockerHandler, opts MetricsOpts) {
 	initMetrics()
 
 	go collectMetrics(handler)
 
-	http.Handle("/metrics", promhttp.Handler())
-	logrus.Fatal(http.ListenAndServe(":9191", nil))
+	mux := http.NewServeMux()
+	mux.Handle(opts.Path, promhttp.Handler())
+	control.RegisterHealth(mux, handler.Health)
+
+	logrus.Fatal(serveMetrics(mux, opts))
+}
+
+func serveMetrics(mux http.Handler, opts MetricsOpts) error {
+	if (opts.TLSCert == "") != (opts.TLSKey == "") {
+		return errors.New("both a TLS certificate and key are required")
+	}
+	if opts.ClientCA != "" && opts.TLSCert == "" {
+		return errors.New("client certificate verification requires TLS")
+	}
+
+	var h http.Handler = mux
+	if opts.Token != "" {
+		h = requireToken(opts.Token, mux)
+	}
+	server := &http.Server{Handler: h}
+
+	if opts.ClientCA != "" {
+		pem, err := ioutil.ReadFile(opts.ClientCA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", opts.ClientCA)
+		}
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			MinVersion: tls.VersionTLS12,
+		}
+	} else if opts.TLSCert != "" {
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	listener, err := metricsListener(opts.Listen)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	logrus.Infof("Metrics listening on %s", opts.Listen)
+	if opts.TLSCert != "" {
+		return server.ServeTLS(listener, opts.TLSCert, opts.TLSKey)
+	}
+	return server.Serve(listener)
+}
+
+// metricsListener listens on a TCP address, or on a Unix socket given as unix:///path
+func metricsListener(address string) (net.Listener, error) {
+	if !strings.HasPrefix(address, "unix://") {
+		return net.Listen("tcp", address)
+	}
+
+	path := strings.TrimPrefix(address, "unix://")
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Scrapers commonly run as a different user sharing the daemon's group
+	if err := os.Chmod(path, 0660); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// requireToken rejects requests without an "Authorization: Bearer <token>" header
+func requireToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// metricsToken returns the bearer token from --metrics-token or --metrics-token-file
+func metricsToken(token, tokenFile string) (string, error) {
+	if tokenFile == "" {
+		return token, nil
+	}
+	data, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
 }
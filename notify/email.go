@@ -0,0 +1,235 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPConfig describes how to reach the mail server
+type SMTPConfig struct {
+	Host string
+	Port int
+	// TLS connects over implicit TLS, as servers listening on port 465 expect,
+	// instead of upgrading the connection with STARTTLS
+	TLS      bool
+	StartTLS bool
+	Username string
+	Password string
+	From     string
+}
+
+// Email sends events to the addresses set on their job. With a digest interval,
+// events are collected and every address gets at most one email per interval.
+type Email struct {
+	config SMTPConfig
+	// rootCAs verify the server's certificate, the system's when nil
+	rootCAs *x509.CertPool
+
+	digest  time.Duration
+	pending map[string][]Event
+	lock    sync.Mutex
+}
+
+// NewEmail returns a notifier sending emails through the SMTP server in config
+func NewEmail(config SMTPConfig, digest time.Duration) *Email {
+	e := &Email{
+		config:  config,
+		digest:  digest,
+		pending: map[string][]Event{},
+	}
+
+	if digest > 0 {
+		go e.flushEvery(digest)
+	}
+	return e
+}
+
+// Notify implements Notifier
+func (e *Email) Notify(event Event) {
+	if len(event.Email) == 0 {
+		return
+	}
+
+	if e.digest <= 0 {
+		e.send(event.Email, eventSubject(event), eventBody(event))
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, address := range event.Email {
+		e.pending[address] = append(e.pending[address], event)
+	}
+}
+
+func (e *Email) flushEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		e.flush()
+	}
+}
+
+// flush sends every address one email listing the events collected for it
+func (e *Email) flush() {
+	e.lock.Lock()
+	pending := e.pending
+	e.pending = map[string][]Event{}
+	e.lock.Unlock()
+
+	for address, events := range pending {
+		failed := 0
+		for _, event := range events {
+			if !event.Success {
+				failed++
+			}
+		}
+
+		subject := fmt.Sprintf("[container-crontab] %d runs, %d failed", len(events), failed)
+		bodies := []string{}
+		for _, event := range events {
+			bodies = append(bodies, eventBody(event))
+		}
+		e.send([]string{address}, subject, strings.Join(bodies, "\r\n----\r\n\r\n"))
+	}
+}
+
+func (e *Email) send(to []string, subject, body string) {
+	message := e.message(to, subject, body)
+	go deliver(strings.Join(to, ", "), func() (bool, error) {
+		return e.sendMail(to, message)
+	})
+}
+
+func (e *Email) message(to []string, subject, body string) []byte {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(body)
+	return message.Bytes()
+}
+
+// sendMail delivers message and returns whether a failed delivery is worth retrying.
+// Permanent SMTP errors, with a 5xx code, are not.
+func (e *Email) sendMail(to []string, message []byte) (bool, error) {
+	err := e.smtpSend(to, message)
+	if protoErr, ok := err.(*textproto.Error); ok && protoErr.Code >= 500 {
+		return false, err
+	}
+	return true, err
+}
+
+func (e *Email) smtpSend(to []string, message []byte) error {
+	c, err := e.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if e.config.StartTLS && !e.config.TLS {
+		if err := c.StartTLS(e.tlsConfig()); err != nil {
+			return err
+		}
+	}
+
+	if e.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(e.config.From); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := c.Rcpt(address); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (e *Email) dial() (*smtp.Client, error) {
+	address := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+	if !e.config.TLS {
+		return smtp.Dial(address)
+	}
+
+	conn, err := tls.Dial("tcp", address, e.tlsConfig())
+	if err != nil {
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (e *Email) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: e.config.Host, RootCAs: e.rootCAs}
+}
+
+func eventSubject(event Event) string {
+	if event.Success {
+		return fmt.Sprintf("[container-crontab] Job %s succeeded", event.Job)
+	}
+	return fmt.Sprintf("[container-crontab] Job %s failed", event.Job)
+}
+
+func eventBody(event Event) string {
+	lines := []string{
+		event.Summary(),
+		"",
+		"Container: " + event.Container,
+		"Job ID:    " + event.ID,
+		"Action:    " + event.Action,
+		fmt.Sprintf("Run:       %d (attempt %d, %s trigger)", event.Run, event.Attempt, event.Trigger),
+		"Scheduled: " + event.Scheduled.Format(time.RFC3339),
+		"Started:   " + event.Start.Format(time.RFC3339),
+		"Duration:  " + event.duration().String(),
+	}
+	if event.Error != "" {
+		lines = append(lines, "Error:     "+event.Error)
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// ParseAddresses splits a comma separated list of email addresses
+func ParseAddresses(value string) ([]string, error) {
+	addresses := []string{}
+	for _, address := range strings.Split(value, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		if !strings.Contains(address, "@") || strings.ContainsAny(address, " \r\n<>") {
+			return nil, fmt.Errorf("Invalid email address: %s", address)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,289 @@
+package notify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// smtpServer is a minimal SMTP server keeping the messages it receives
+type smtpServer struct {
+	listener net.Listener
+	// rcptCode answers RCPT commands, 250 when zero
+	rcptCode int
+	messages chan string
+	conns    int32
+}
+
+func startSMTP(t *testing.T, rcptCode int) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveSMTP(listener, rcptCode)
+}
+
+// startSMTPS starts a server speaking implicit TLS, returning the pool trusting its certificate
+func startSMTPS(t *testing.T) (*smtpServer, *x509.CertPool) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Borrows the certificate of an httptest server, valid for 127.0.0.1
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	defer https.Close()
+	pool := https.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	return serveSMTP(tls.NewListener(listener, https.TLS), 0), pool
+}
+
+func serveSMTP(listener net.Listener, rcptCode int) *smtpServer {
+	s := &smtpServer{
+		listener: listener,
+		rcptCode: rcptCode,
+		messages: make(chan string, 10),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.conns, 1)
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch strings.ToUpper(strings.SplitN(line, " ", 2)[0]) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			if s.rcptCode != 0 {
+				tp.PrintfLine("%d recipient rejected", s.rcptCode)
+				continue
+			}
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.messages <- string(data)
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) config() SMTPConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return SMTPConfig{
+		Host: addr.IP.String(),
+		Port: addr.Port,
+		From: "crontab@example.com",
+	}
+}
+
+func (s *smtpServer) message(t *testing.T) string {
+	select {
+	case message := <-s.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+		return ""
+	}
+}
+
+func (s *smtpServer) noMessage(t *testing.T) {
+	select {
+	case message := <-s.messages:
+		t.Fatalf("unexpected email:\n%s", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func testEvent(job string, success bool, email ...string) Event {
+	event := Event{
+		Job:     job,
+		ID:      job,
+		Action:  "exec",
+		Trigger: "schedule",
+		Run:     1,
+		Success: success,
+		Email:   email,
+	}
+	if !success {
+		event.Error = "exit status 1"
+	}
+	return event
+}
+
+func TestEmailSendsEachEvent(t *testing.T) {
+	server := startSMTP(t, 0)
+	defer server.listener.Close()
+
+	e := NewEmail(server.config(), 0)
+	e.Notify(testEvent("backup", false, "ops@example.com", "dev@example.com"))
+
+	message := server.message(t)
+	for _, header := range []string{
+		"From: crontab@example.com",
+		"To: ops@example.com, dev@example.com",
+		"Subject: [container-crontab] Job backup failed",
+	} {
+		if !strings.Contains(message, header) {
+			t.Errorf("missing %q in:\n%s", header, message)
+		}
+	}
+	if !strings.Contains(message, "Error:     exit status 1") {
+		t.Errorf("missing the error in:\n%s", message)
+	}
+}
+
+func TestEmailImplicitTLS(t *testing.T) {
+	server, pool := startSMTPS(t)
+	defer server.listener.Close()
+
+	config := server.config()
+	config.TLS = true
+	config.StartTLS = true
+	e := NewEmail(config, 0)
+	e.rootCAs = pool
+
+	if _, err := e.sendMail([]string{"ops@example.com"}, []byte("Subject: test\r\n\r\ntest\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if message := server.message(t); !strings.Contains(message, "Subject: test") {
+		t.Errorf("unexpected email:\n%s", message)
+	}
+}
+
+func TestEmailIgnoresEventsWithoutAddresses(t *testing.T) {
+	server := startSMTP(t, 0)
+	defer server.listener.Close()
+
+	e := NewEmail(server.config(), 0)
+	e.Notify(testEvent("backup", false))
+	server.noMessage(t)
+}
+
+func TestEmailDigest(t *testing.T) {
+	server := startSMTP(t, 0)
+	defer server.listener.Close()
+
+	e := NewEmail(server.config(), time.Hour)
+	e.Notify(testEvent("backup", true, "ops@example.com"))
+	e.Notify(testEvent("report", false, "ops@example.com"))
+	e.Notify(testEvent("cleanup", true, "ops@example.com", "dev@example.com"))
+	server.noMessage(t)
+
+	e.flush()
+
+	messages := map[string]string{}
+	for i := 0; i < 2; i++ {
+		message := server.message(t)
+		switch {
+		case strings.Contains(message, "To: ops@example.com\n"):
+			messages["ops"] = message
+		case strings.Contains(message, "To: dev@example.com\n"):
+			messages["dev"] = message
+		default:
+			t.Fatalf("email to an unexpected address:\n%s", message)
+		}
+	}
+	server.noMessage(t)
+
+	if !strings.Contains(messages["ops"], "Subject: [container-crontab] 3 runs, 1 failed") {
+		t.Errorf("wrong digest subject:\n%s", messages["ops"])
+	}
+	for _, job := range []string{"backup", "report", "cleanup"} {
+		if !strings.Contains(messages["ops"], "Job "+job) {
+			t.Errorf("job %s missing from digest:\n%s", job, messages["ops"])
+		}
+	}
+	if !strings.Contains(messages["dev"], "Subject: [container-crontab] 1 runs, 0 failed") {
+		t.Errorf("wrong digest subject:\n%s", messages["dev"])
+	}
+
+	// Events are only sent once
+	e.flush()
+	server.noMessage(t)
+}
+
+func TestSendMailRetries(t *testing.T) {
+	for _, test := range []struct {
+		code  int
+		retry bool
+	}{
+		{code: 550, retry: false},
+		{code: 451, retry: true},
+	} {
+		server := startSMTP(t, test.code)
+
+		e := NewEmail(server.config(), 0)
+		retry, err := e.sendMail([]string{"ops@example.com"}, []byte("Subject: test\r\n\r\ntest\r\n"))
+		if err == nil {
+			t.Errorf("%d: expected an error", test.code)
+		}
+		if retry != test.retry {
+			t.Errorf("%d: expected retry %t, got %t", test.code, test.retry, retry)
+		}
+		if conns := atomic.LoadInt32(&server.conns); conns != 1 {
+			t.Errorf("%d: expected 1 connection, got %d", test.code, conns)
+		}
+		server.listener.Close()
+	}
+}
+
+func TestDeliverStopsOnPermanentErrors(t *testing.T) {
+	server := startSMTP(t, 550)
+	defer server.listener.Close()
+
+	e := NewEmail(server.config(), 0)
+	done := make(chan struct{})
+	go func() {
+		deliver("ops@example.com", func() (bool, error) {
+			return e.sendMail([]string{"ops@example.com"}, []byte("Subject: test\r\n\r\ntest\r\n"))
+		})
+		close(done)
+	}()
+
+	// A retry would wait deliveryBackoff first
+	select {
+	case <-done:
+	case <-time.After(deliveryBackoff):
+		t.Fatal("permanent error was retried")
+	}
+	if conns := atomic.LoadInt32(&server.conns); conns != 1 {
+		t.Errorf("expected 1 connection, got %d", conns)
+	}
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	deliveryAttempts = 5
+	deliveryBackoff  = 2 * time.Second
+)
+
+// Event describes the outcome of a job run
+type Event struct {
+	Job       string    `json:"job"`
+	ID        string    `json:"id"`
+	Container string    `json:"container"`
+	Action    string    `json:"action"`
+	Trigger   string    `json:"trigger"`
+	Run       int       `json:"run"`
+	Attempt   int       `json:"attempt"`
+	Scheduled time.Time `json:"scheduled"`
+	Start     time.Time `json:"start"`
+	Duration  float64   `json:"duration_seconds"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+
+	// Email lists the addresses the job asked to be emailed about its runs
+	Email []string `json:"-"`
+}
+
+// Notifier delivers events. Notify must not block, delivery happens in the background.
+type Notifier interface {
+	Notify(Event)
+}
+
+// Multi sends every event to each of its notifiers
+type Multi []Notifier
+
+// Notify implements Notifier
+func (m Multi) Notify(event Event) {
+	for _, notifier := range m {
+		notifier.Notify(event)
+	}
+}
+
+// deliver calls send until it succeeds, fails with an error not worth retrying
+// or fails deliveryAttempts times, doubling the wait after every failure
+func deliver(recipient string, send func() (bool, error)) {
+	backoff := deliveryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := send()
+		if err == nil {
+			logrus.Debugf("Notified %s", recipient)
+			return
+		}
+		if !retry || attempt == deliveryAttempts {
+			logrus.Errorf("Error notifying %s: %s", recipient, err)
+			return
+		}
+		logrus.Warnf("Error notifying %s, retrying in %s: %s", recipient, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Summary is a one line description of the event
+func (e Event) Summary() string {
+	if e.Success {
+		return fmt.Sprintf("Job %s succeeded: %s took %s", e.Job, e.Action, e.duration())
+	}
+	return fmt.Sprintf("Job %s failed: %s after %s: %s", e.Job, e.Action, e.duration(), e.Error)
+}
+
+func (e Event) duration() time.Duration {
+	return time.Duration(e.Duration * float64(time.Second)).Round(time.Millisecond)
+}
@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// FormatJSON posts the Event as is
+	FormatJSON = "json"
+	// FormatSlack posts a message understood by Slack and Mattermost incoming webhooks
+	FormatSlack = "slack"
+)
+
+// Webhook POSTs events to a URL
+type Webhook struct {
+	URL    string
+	Format string
+	client *http.Client
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// NewWebhook returns a notifier posting events to url in the given format
+func NewWebhook(url, format string) *Webhook {
+	return &Webhook{
+		URL:    url,
+		Format: format,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify implements Notifier
+func (w *Webhook) Notify(event Event) {
+	var payload interface{} = event
+	if w.Format == FormatSlack {
+		payload = slackMessage{Text: slackText(event)}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logrus.Errorf("Error encoding notification for %s: %s", event.Job, err)
+		return
+	}
+
+	go deliver(fmt.Sprintf("%s about %s", w.URL, event.Job), func() (bool, error) {
+		return w.post(body)
+	})
+}
+
+// post sends body and returns whether a failed delivery is worth retrying
+func (w *Webhook) post(body []byte) (bool, error) {
+	resp, err := w.client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return false, nil
+}
+
+func slackText(event Event) string {
+	icon := ":white_check_mark:"
+	if !event.Success {
+		icon = ":x:"
+	}
+
+	return fmt.Sprintf("%s %s\nContainer: `%s`, run %d, %s trigger, scheduled at %s",
+		icon, event.Summary(), event.Container, event.Run, event.Trigger, event.Scheduled.Format(time.RFC3339))
+}